@@ -0,0 +1,493 @@
+// config/config.go
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfigFile points to an optional YAML file with the same structure as Config
+const EnvConfigFile = "CONFIG_FILE"
+
+// Config — вся конфигурация сервиса. Порядок источников (каждый следующий перекрывает предыдущий):
+// значения из тегов `default`, YAML-файл из CONFIG_FILE, .env, переменные окружения (тег `env`).
+// Поля с тегом `secret` маскируются в Redacted().
+type Config struct {
+	HTTP            HTTPConfig            `yaml:"http"`
+	Log             LogConfig             `yaml:"log"`
+	Database        DatabaseConfig        `yaml:"database"`
+	Redis           RedisConfig           `yaml:"redis"`
+	Storage         StorageConfig         `yaml:"storage"`
+	Auth            AuthConfig            `yaml:"auth"`
+	Tracing         TracingConfig         `yaml:"tracing"`
+	Metrics         MetricsConfig         `yaml:"metrics"`
+	Outbox          OutboxConfig          `yaml:"outbox"`
+	Webhooks        WebhooksConfig        `yaml:"webhooks"`
+	Live            LiveConfig            `yaml:"live"`
+	Trash           TrashConfig           `yaml:"trash"`
+	Moderation      ModerationConfig      `yaml:"moderation"`
+	Reports         ReportsConfig         `yaml:"reports"`
+	Analytics       AnalyticsConfig       `yaml:"analytics"`
+	Trending        TrendingConfig        `yaml:"trending"`
+	Recommendations RecommendationsConfig `yaml:"recommendations"`
+
+	// Sources lists where the values came from, for the startup log
+	Sources []string `yaml:"-"`
+}
+
+type HTTPConfig struct {
+	Port              int           `yaml:"port" env:"PORT" default:"8080"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" default:"60s"` // загрузка файлов
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" default:"90s"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" default:"120s"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"` // drain HTTP-запросов
+	// Отдельный срок на остановку фоновых воркеров после drain; затем закрываются Postgres и Redis
+	WorkerStopTimeout time.Duration `yaml:"worker_stop_timeout" env:"WORKER_STOP_TIMEOUT" default:"15s"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL" default:"info"`
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url" env:"DATABASE_URL" secret:"url"`
+}
+
+type RedisConfig struct {
+	URL string `yaml:"url" env:"REDIS_URL" secret:"url"`
+}
+
+type StorageConfig struct {
+	// Пустой путь — Application Default Credentials
+	CredentialsFile string `yaml:"credentials_file" env:"GOOGLE_APPLICATION_CREDENTIALS"`
+	Bucket          string `yaml:"bucket" env:"FIREBASE_BUCKET_NAME"`
+}
+
+type AuthConfig struct {
+	JWTSecret string `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+}
+
+type TracingConfig struct {
+	// otlp | console | stdout (то же, что console) | none
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME" default:"device-service"`
+}
+
+type MetricsConfig struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"METRICS_REFRESH_INTERVAL" default:"30s"`
+}
+
+type OutboxConfig struct {
+	// redis | stdout | memory
+	Sink         string        `yaml:"sink" env:"OUTBOX_SINK" default:"redis"`
+	Stream       string        `yaml:"stream" env:"OUTBOX_STREAM" default:"device-events"`
+	StreamMaxLen int64         `yaml:"stream_max_len" env:"OUTBOX_STREAM_MAX_LEN" default:"100000"`
+	BatchSize    int           `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE" default:"100"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL" default:"1s"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env:"OUTBOX_MAX_BACKOFF" default:"5m"`
+	Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION" default:"168h"`
+}
+
+type WebhooksConfig struct {
+	Enabled      bool          `yaml:"enabled" env:"WEBHOOKS_ENABLED" default:"true"`
+	Timeout      time.Duration `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" default:"10s"`
+	BatchSize    int           `yaml:"batch_size" env:"WEBHOOKS_BATCH_SIZE" default:"20"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOKS_POLL_INTERVAL" default:"2s"`
+	MaxAttempts  int           `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" default:"10"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env:"WEBHOOKS_MAX_BACKOFF" default:"1h"`
+	// Подписка отключается после стольких неудачных доставок подряд
+	DisableAfter int `yaml:"disable_after" env:"WEBHOOKS_DISABLE_AFTER" default:"50"`
+	// Разрешить доставку на 127.0.0.1/localhost — только для локальных получателей в тестах.
+	// Частные, link-local и прочие внутренние адреса запрещены всегда
+	AllowLoopback bool `yaml:"allow_loopback" env:"WEBHOOKS_ALLOW_LOOPBACK" default:"false"`
+}
+
+type LiveConfig struct {
+	// Redis pub/sub канал, через который реплики получают события для SSE
+	Channel   string        `yaml:"channel" env:"LIVE_CHANNEL" default:"device-events:live"`
+	Heartbeat time.Duration `yaml:"heartbeat" env:"LIVE_HEARTBEAT" default:"15s"`
+	// После этого поток закрывается, клиент переподключается (EventSource делает это сам)
+	MaxStreamDuration time.Duration `yaml:"max_stream_duration" env:"LIVE_MAX_STREAM_DURATION" default:"30m"`
+	Buffer            int           `yaml:"buffer" env:"LIVE_BUFFER" default:"32"`
+}
+
+type TrashConfig struct {
+	// Сколько удалённое устройство лежит в корзине до окончательного удаления
+	Retention     time.Duration `yaml:"retention" env:"TRASH_RETENTION" default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"TRASH_PURGE_INTERVAL" default:"1h"`
+	PurgeBatch    int           `yaml:"purge_batch" env:"TRASH_PURGE_BATCH" default:"500"`
+}
+
+type ModerationConfig struct {
+	// Слова и фразы (без учёта регистра), из-за которых объявление помечается; через запятую в env
+	BannedWords  []string `yaml:"banned_words" env:"MODERATION_BANNED_WORDS"`
+	RequireImage bool     `yaml:"require_image" env:"MODERATION_REQUIRE_IMAGE" default:"true"`
+	// Цена подозрительна, если отличается от медианы категории больше чем в столько раз; 0 — не проверять
+	PriceFactor float64 `yaml:"price_factor" env:"MODERATION_PRICE_FACTOR" default:"5"`
+	// Медиана учитывается, только если в категории столько опубликованных устройств
+	PriceMinSamples int `yaml:"price_min_samples" env:"MODERATION_PRICE_MIN_SAMPLES" default:"10"`
+}
+
+type ReportsConfig struct {
+	// Столько открытых жалоб от разных пользователей скрывают объявление до проверки модератором
+	HideThreshold int `yaml:"hide_threshold" env:"REPORTS_HIDE_THRESHOLD" default:"3"`
+}
+
+type AnalyticsConfig struct {
+	// Как часто просмотры из Redis переносятся в device_daily_stats
+	FlushInterval time.Duration `yaml:"flush_interval" env:"ANALYTICS_FLUSH_INTERVAL" default:"1m"`
+}
+
+type TrendingConfig struct {
+	// Как часто пересчитываются рейтинги популярных устройств
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"TRENDING_REFRESH_INTERVAL" default:"5m"`
+	// Сколько устройств хранится в каждом рейтинге (окно × категория/город)
+	Size int `yaml:"size" env:"TRENDING_SIZE" default:"500"`
+	// Вес одного добавления в избранное и одного просмотра
+	FavoriteWeight float64 `yaml:"favorite_weight" env:"TRENDING_FAVORITE_WEIGHT" default:"5"`
+	ViewWeight     float64 `yaml:"view_weight" env:"TRENDING_VIEW_WEIGHT" default:"1"`
+}
+
+type RecommendationsConfig struct {
+	// Как часто модель «добавившие это в избранное добавили и…» строится заново
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"RECOMMENDATIONS_REFRESH_INTERVAL" default:"1h"`
+	// Сколько похожих устройств хранится для каждого устройства
+	Neighbors int `yaml:"neighbors" env:"RECOMMENDATIONS_NEIGHBORS" default:"50"`
+	// Пользователи с большим числом избранного не учитываются: они дают квадратичное число пар и мало сигнала
+	MaxUserFavorites int `yaml:"max_user_favorites" env:"RECOMMENDATIONS_MAX_USER_FAVORITES" default:"500"`
+}
+
+// Load builds and validates the configuration
+func Load() (*Config, error) {
+	var cfg Config
+	if err := walk(reflect.ValueOf(&cfg).Elem(), "", setDefault); err != nil {
+		return nil, err
+	}
+	cfg.Sources = append(cfg.Sources, "defaults")
+
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		if err := cfg.loadYAML(path); err != nil {
+			return nil, err
+		}
+		cfg.Sources = append(cfg.Sources, path)
+	}
+
+	// .env не перекрывает уже заданные переменные окружения
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			return nil, fmt.Errorf("load .env: %w", err)
+		}
+		cfg.Sources = append(cfg.Sources, ".env")
+	}
+
+	if err := walk(reflect.ValueOf(&cfg).Elem(), "", setFromEnv); err != nil {
+		return nil, err
+	}
+	cfg.Sources = append(cfg.Sources, "env")
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) loadYAML(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true) // опечатка в ключе — ошибка, а не молчаливый дефолт
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.HTTP.Port < 1 || c.HTTP.Port > 65535 {
+		fail("PORT must be between 1 and 65535, got %d", c.HTTP.Port)
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("LOG_LEVEL must be one of debug, info, warn, error, got %q", c.Log.Level)
+	}
+
+	if c.Database.URL == "" {
+		fail("DATABASE_URL is required")
+	}
+
+	if c.Redis.URL == "" {
+		fail("REDIS_URL is required")
+	} else if _, err := redis.ParseURL(c.Redis.URL); err != nil {
+		fail("REDIS_URL is invalid: %v", err)
+	}
+
+	if c.Storage.Bucket == "" {
+		fail("FIREBASE_BUCKET_NAME is required")
+	}
+	if c.Storage.CredentialsFile != "" {
+		if _, err := os.Stat(c.Storage.CredentialsFile); err != nil {
+			fail("GOOGLE_APPLICATION_CREDENTIALS: %v", err)
+		}
+	}
+
+	if c.Auth.JWTSecret == "" {
+		fail("JWT_SECRET is required")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "console", "stdout":
+	default:
+		fail("OTEL_TRACES_EXPORTER must be one of otlp, console, stdout, none, got %q", c.Tracing.Exporter)
+	}
+
+	switch c.Outbox.Sink {
+	case "redis", "stdout", "memory":
+	default:
+		fail("OUTBOX_SINK must be one of redis, stdout, memory, got %q", c.Outbox.Sink)
+	}
+	if c.Outbox.Sink == "redis" && c.Outbox.Stream == "" {
+		fail("OUTBOX_STREAM is required for the redis sink")
+	}
+	if c.Outbox.BatchSize < 1 || c.Outbox.BatchSize > 10000 {
+		fail("OUTBOX_BATCH_SIZE must be between 1 and 10000, got %d", c.Outbox.BatchSize)
+	}
+
+	if c.Webhooks.BatchSize < 1 || c.Webhooks.BatchSize > 1000 {
+		fail("WEBHOOKS_BATCH_SIZE must be between 1 and 1000, got %d", c.Webhooks.BatchSize)
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		fail("WEBHOOKS_MAX_ATTEMPTS must be at least 1, got %d", c.Webhooks.MaxAttempts)
+	}
+	if c.Webhooks.DisableAfter < 1 {
+		fail("WEBHOOKS_DISABLE_AFTER must be at least 1, got %d", c.Webhooks.DisableAfter)
+	}
+
+	if c.Live.Channel == "" {
+		fail("LIVE_CHANNEL is required")
+	}
+	if c.Live.Buffer < 1 {
+		fail("LIVE_BUFFER must be at least 1, got %d", c.Live.Buffer)
+	}
+
+	if c.Trash.PurgeBatch < 1 {
+		fail("TRASH_PURGE_BATCH must be at least 1, got %d", c.Trash.PurgeBatch)
+	}
+
+	if c.Moderation.PriceFactor != 0 && c.Moderation.PriceFactor <= 1 {
+		fail("MODERATION_PRICE_FACTOR must be greater than 1 (or 0 to disable), got %v", c.Moderation.PriceFactor)
+	}
+	if c.Moderation.PriceMinSamples < 1 {
+		fail("MODERATION_PRICE_MIN_SAMPLES must be at least 1, got %d", c.Moderation.PriceMinSamples)
+	}
+
+	if c.Reports.HideThreshold < 1 {
+		fail("REPORTS_HIDE_THRESHOLD must be at least 1, got %d", c.Reports.HideThreshold)
+	}
+
+	if c.Trending.Size < 1 {
+		fail("TRENDING_SIZE must be at least 1, got %d", c.Trending.Size)
+	}
+	if c.Trending.FavoriteWeight < 0 || c.Trending.ViewWeight < 0 {
+		fail("TRENDING_FAVORITE_WEIGHT and TRENDING_VIEW_WEIGHT must not be negative")
+	}
+	if c.Trending.FavoriteWeight == 0 && c.Trending.ViewWeight == 0 {
+		fail("at least one of TRENDING_FAVORITE_WEIGHT and TRENDING_VIEW_WEIGHT must be positive")
+	}
+
+	if c.Recommendations.Neighbors < 1 {
+		fail("RECOMMENDATIONS_NEIGHBORS must be at least 1, got %d", c.Recommendations.Neighbors)
+	}
+	if c.Recommendations.MaxUserFavorites < 2 {
+		fail("RECOMMENDATIONS_MAX_USER_FAVORITES must be at least 2, got %d", c.Recommendations.MaxUserFavorites)
+	}
+
+	// Все таймауты и интервалы должны быть положительными
+	_ = walk(reflect.ValueOf(c).Elem(), "", func(f reflect.StructField, v reflect.Value, name string) error {
+		if v.Type() == durationType && v.Int() <= 0 {
+			fail("%s must be a positive duration", envOrName(f, name))
+		}
+		return nil
+	})
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns the configuration as a nested map with secrets masked, for logging
+func (c *Config) Redacted() map[string]interface{} {
+	out := map[string]interface{}{}
+	_ = walk(reflect.ValueOf(c).Elem(), "", func(f reflect.StructField, v reflect.Value, name string) error {
+		var val interface{} = v.Interface()
+		if v.Type() == durationType {
+			val = v.Interface().(time.Duration).String()
+		}
+		switch f.Tag.Get("secret") {
+		case "true":
+			if !v.IsZero() {
+				val = "******"
+			}
+		case "url":
+			val = redactURL(v.String())
+		}
+
+		// "database.url" → out["database"]["url"]
+		parts := strings.Split(name, ".")
+		m := out
+		for _, p := range parts[:len(parts)-1] {
+			next, ok := m[p].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[p] = next
+			}
+			m = next
+		}
+		m[parts[len(parts)-1]] = val
+		return nil
+	})
+	return out
+}
+
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "******"
+	}
+	return u.Redacted()
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walk calls fn for every leaf field of the struct v; name is the dotted yaml path
+func walk(v reflect.Value, prefix string, fn func(f reflect.StructField, v reflect.Value, name string) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		yamlName := strings.SplitN(f.Tag.Get("yaml"), ",", 2)[0]
+		if yamlName == "-" {
+			continue
+		}
+		name := yamlName
+		if prefix != "" {
+			name = prefix + "." + yamlName
+		}
+
+		fv := v.Field(i)
+		if f.Type.Kind() == reflect.Struct && f.Type != durationType {
+			if err := walk(fv, name, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(f, fv, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setDefault(f reflect.StructField, v reflect.Value, name string) error {
+	def, ok := f.Tag.Lookup("default")
+	if !ok {
+		return nil
+	}
+	if err := setValue(v, def); err != nil {
+		return fmt.Errorf("config: bad default for %s: %w", name, err)
+	}
+	return nil
+}
+
+func setFromEnv(f reflect.StructField, v reflect.Value, _ string) error {
+	key := f.Tag.Get("env")
+	if key == "" {
+		return nil
+	}
+	raw, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	if err := setValue(v, raw); err != nil {
+		return fmt.Errorf("config: invalid %s=%q: %w", key, raw, err)
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported slice type %s", v.Type())
+		}
+		var items []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func envOrName(f reflect.StructField, name string) string {
+	if env := f.Tag.Get("env"); env != "" {
+		return env
+	}
+	return name
+}
@@ -2,29 +2,30 @@ package config
 
 import (
 	"context"
-	"log"
-	"os"
+	"fmt"
+	"log/slog"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
-var RedisClient *redis.Client
-
-func InitRedis() {
-	// 1) Подгрузили URL (который у вас уже в REDIS_URL)
-	redURL := os.Getenv("REDIS_URL")
-	opt, err := redis.ParseURL(redURL)
+// NewRedisClient создаёт клиента по REDIS_URL (TLS для rediss:// — автоматически) и проверяет соединение
+func NewRedisClient(ctx context.Context, cfg RedisConfig) (*redis.Client, error) {
+	opt, err := redis.ParseURL(cfg.URL)
 	if err != nil {
-		log.Fatalf("🔴 redis parse url failed: %v", err)
+		return nil, fmt.Errorf("redis parse url: %w", err)
 	}
 
-	// 2) Создали клиента по этому URL (с TLS автоматически)
-	RedisClient = redis.NewClient(opt)
-
-	// 3) Проверили соединение
-	if err := RedisClient.Ping(context.Background()).Err(); err != nil {
-		log.Fatalf("🔴 redis connection failed: %v", err)
+	client := redis.NewClient(opt)
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("redis tracing instrumentation: %w", err)
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("redis connection: %w", err)
 	}
 
-	log.Println("✅ Redis connected via URL")
+	slog.Info("Redis connected", "addr", opt.Addr)
+	return client, nil
 }
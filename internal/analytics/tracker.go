@@ -0,0 +1,232 @@
+// Package analytics counts device views and list impressions. Hits are de-duplicated
+// per viewer and UTC day and buffered in Redis; Flush moves the buffered counters into
+// device_daily_stats. A lost flush loses counts rather than doubling them.
+package analytics
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+// Hit kinds
+const (
+	KindView       = "views"       // GET /api/devices/:id
+	KindImpression = "impressions" // the device appeared in GET /api/devices
+)
+
+const (
+	// A viewer is counted once per device, kind and day; the marker outlives the day
+	seenTTL = 48 * time.Hour
+
+	// Buffered counts that could not be flushed for this long are dropped, so an
+	// outage can't grow Redis forever; a claimed buffer of a crashed flush likewise
+	pendingTTL  = 7 * 24 * time.Hour
+	flushingTTL = 24 * time.Hour
+
+	// Days with buffered counts, so Flush finds old days after an outage
+	pendingDaysKey = "stats:pending:days"
+)
+
+// claimScript renames the buffer away for flushing, if there is one. Both keys carry
+// the same hash tag (see pendingKey), so the script works on Redis Cluster too.
+var claimScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+    return 0
+end
+redis.call('RENAME', KEYS[1], KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[1])
+return 1
+`)
+
+// Tracker buffers hits in Redis and flushes them to Postgres
+type Tracker struct {
+	Redis redis.UniversalClient
+	DB    *sqlx.DB
+}
+
+func NewTracker(rdb redis.UniversalClient, db *sqlx.DB) *Tracker {
+	return &Tracker{Redis: rdb, DB: db}
+}
+
+// Track counts a hit of kind by viewerID on each device, at most once per viewer and day
+func (t *Tracker) Track(ctx context.Context, kind, viewerID string, deviceIDs ...string) error {
+	if len(deviceIDs) == 0 {
+		return nil
+	}
+	day := Day(time.Now())
+
+	pipe := t.Redis.Pipeline()
+	seen := make([]*redis.BoolCmd, len(deviceIDs))
+	for i, id := range deviceIDs {
+		seen[i] = pipe.SetNX(ctx, fmt.Sprintf("stats:seen:%s:%s:%s:%s", day, kind, id, viewerID), 1, seenTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("dedupe %s: %w", kind, err)
+	}
+
+	pipe = t.Redis.Pipeline()
+	n := 0
+	for i, id := range deviceIDs {
+		if seen[i].Val() {
+			pipe.HIncrBy(ctx, pendingKey(day), id+":"+kind, 1)
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	pipe.Expire(ctx, pendingKey(day), pendingTTL)
+	pipe.SAdd(ctx, pendingDaysKey, day)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("count %s: %w", kind, err)
+	}
+	return nil
+}
+
+// Flush moves the buffered counters of every pending day into device_daily_stats.
+// Safe to run on every replica: the buffer is renamed away before it is read.
+func (t *Tracker) Flush(ctx context.Context) error {
+	now := time.Now()
+	yesterday, today := Day(now.AddDate(0, 0, -1)), Day(now)
+
+	days, err := t.Redis.SMembers(ctx, pendingDaysKey).Result()
+	if err != nil {
+		return fmt.Errorf("list pending stats days: %w", err)
+	}
+	sort.Strings(days)
+	for _, day := range append(days, yesterday, today) {
+		if err := t.flushDay(ctx, day); err != nil {
+			return err
+		}
+		// Hits are only counted for today, so an older day gets nothing new once flushed
+		if day < yesterday {
+			if err := t.Redis.SRem(ctx, pendingDaysKey, day).Err(); err != nil {
+				return fmt.Errorf("forget stats day %s: %w", day, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) flushDay(ctx context.Context, day string) error {
+	src := pendingKey(day)
+	dst := flushingKey(day, randomSuffix())
+	claimed, err := claimScript.Run(ctx, t.Redis, []string{src, dst}, int(flushingTTL.Seconds())).Int()
+	if err != nil {
+		return fmt.Errorf("claim %s: %w", src, err)
+	}
+	if claimed == 0 {
+		return nil // nothing buffered, or another replica took it
+	}
+
+	counts, err := t.Redis.HGetAll(ctx, dst).Result()
+	if err != nil {
+		return fmt.Errorf("read %s: %w", dst, err)
+	}
+
+	views := map[string]int64{}
+	impressions := map[string]int64{}
+	for field, raw := range counts {
+		id, kind, ok := strings.Cut(field, ":")
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+		switch kind {
+		case KindView:
+			views[id] += n
+		case KindImpression:
+			impressions[id] += n
+		}
+	}
+
+	if err := t.store(ctx, day, views, impressions); err != nil {
+		// Put the counts back so the next flush retries them
+		pipe := t.Redis.Pipeline()
+		for field, raw := range counts {
+			if n, perr := strconv.ParseInt(raw, 10, 64); perr == nil {
+				pipe.HIncrBy(ctx, src, field, n)
+			}
+		}
+		pipe.Expire(ctx, src, pendingTTL)
+		pipe.SAdd(ctx, pendingDaysKey, day)
+		pipe.Del(ctx, dst)
+		if _, rerr := pipe.Exec(ctx); rerr != nil {
+			slog.ErrorContext(ctx, "failed to return unflushed view counts", "key", dst, "error", rerr)
+		}
+		return err
+	}
+
+	if err := t.Redis.Del(ctx, dst).Err(); err != nil {
+		slog.WarnContext(ctx, "failed to delete flushed view counts", "key", dst, "error", err)
+	}
+	slog.DebugContext(ctx, "view counts flushed", "day", day, "devices", len(counts))
+	return nil
+}
+
+// store adds the counts of day to device_daily_stats; purged devices are skipped
+func (t *Tracker) store(ctx context.Context, day string, views, impressions map[string]int64) error {
+	ids := make([]string, 0, len(views)+len(impressions))
+	for id := range views {
+		ids = append(ids, id)
+	}
+	for id := range impressions {
+		if _, ok := views[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	v := make([]int64, len(ids))
+	imp := make([]int64, len(ids))
+	for i, id := range ids {
+		v[i], imp[i] = views[id], impressions[id]
+	}
+
+	_, err := t.DB.ExecContext(ctx, `
+        INSERT INTO device_daily_stats (device_id, day, views, impressions)
+        SELECT c.device_id, CAST($1 AS DATE), c.views, c.impressions
+        FROM unnest(CAST($2 AS UUID[]), CAST($3 AS BIGINT[]), CAST($4 AS BIGINT[])) AS c(device_id, views, impressions)
+        JOIN devices d ON d.id = c.device_id
+        ON CONFLICT (device_id, day) DO UPDATE
+        SET views = device_daily_stats.views + EXCLUDED.views,
+            impressions = device_daily_stats.impressions + EXCLUDED.impressions
+    `, day, pq.Array(ids), pq.Array(v), pq.Array(imp))
+	if err != nil {
+		return fmt.Errorf("store view counts: %w", err)
+	}
+	return nil
+}
+
+// Day is the UTC day of t as stored in device_daily_stats
+func Day(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// pendingKey is the buffer of day. The {…} hash tag puts it and its flushing copies
+// in one cluster slot, as RENAME requires.
+func pendingKey(day string) string {
+	return "stats:{pending:" + day + "}"
+}
+
+func flushingKey(day, suffix string) string {
+	return pendingKey(day) + ":flushing:" + suffix
+}
+
+func randomSuffix() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,263 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+// memRedis answers the commands the tracker sends from memory. It is installed as a
+// hook, so the client never dials; TTLs are ignored.
+type memRedis struct {
+	mu      sync.Mutex
+	strings map[string]string
+	hashes  map[string]map[string]int64
+	sets    map[string]map[string]bool
+}
+
+func newMemRedis() (*memRedis, *redis.Client) {
+	m := &memRedis{strings: map[string]string{}, hashes: map[string]map[string]int64{}, sets: map[string]map[string]bool{}}
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:1"})
+	rdb.AddHook(m)
+	return m, rdb
+}
+
+func (m *memRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("memRedis does not dial")
+	}
+}
+
+func (m *memRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		m.process(cmd)
+		return cmd.Err()
+	}
+}
+
+func (m *memRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			m.process(cmd)
+		}
+		return nil
+	}
+}
+
+func (m *memRedis) process(cmd redis.Cmder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	args := make([]string, len(cmd.Args()))
+	for i, a := range cmd.Args() {
+		args[i] = fmt.Sprint(a)
+	}
+	switch strings.ToLower(args[0]) {
+	case "set": // SET key value EX n NX
+		_, exists := m.strings[args[1]]
+		if !exists {
+			m.strings[args[1]] = args[2]
+		}
+		cmd.(*redis.BoolCmd).SetVal(!exists)
+	case "hincrby":
+		var n int64
+		fmt.Sscan(args[3], &n)
+		if m.hashes[args[1]] == nil {
+			m.hashes[args[1]] = map[string]int64{}
+		}
+		m.hashes[args[1]][args[2]] += n
+		cmd.(*redis.IntCmd).SetVal(m.hashes[args[1]][args[2]])
+	case "hgetall":
+		out := map[string]string{}
+		for f, n := range m.hashes[args[1]] {
+			out[f] = fmt.Sprint(n)
+		}
+		cmd.(*redis.MapStringStringCmd).SetVal(out)
+	case "expire":
+		_, ok := m.hashes[args[1]]
+		cmd.(*redis.BoolCmd).SetVal(ok)
+	case "sadd":
+		if m.sets[args[1]] == nil {
+			m.sets[args[1]] = map[string]bool{}
+		}
+		for _, v := range args[2:] {
+			m.sets[args[1]][v] = true
+		}
+		cmd.(*redis.IntCmd).SetVal(1)
+	case "srem":
+		for _, v := range args[2:] {
+			delete(m.sets[args[1]], v)
+		}
+		cmd.(*redis.IntCmd).SetVal(1)
+	case "smembers":
+		var out []string
+		for v := range m.sets[args[1]] {
+			out = append(out, v)
+		}
+		cmd.(*redis.StringSliceCmd).SetVal(out)
+	case "del":
+		for _, k := range args[1:] {
+			delete(m.hashes, k)
+		}
+		cmd.(*redis.IntCmd).SetVal(1)
+	case "evalsha", "eval": // claimScript: EVALSHA sha 2 src dst ttl
+		src, dst := args[3], args[4]
+		if hashSlotTag(src) != hashSlotTag(dst) {
+			cmd.SetErr(fmt.Errorf("CROSSSLOT Keys in request don't hash to the same slot"))
+			return
+		}
+		h, ok := m.hashes[src]
+		if !ok {
+			cmd.(*redis.Cmd).SetVal(int64(0))
+			return
+		}
+		delete(m.hashes, src)
+		m.hashes[dst] = h
+		cmd.(*redis.Cmd).SetVal(int64(1))
+	default:
+		cmd.SetErr(fmt.Errorf("memRedis: unsupported command %s", args[0]))
+	}
+}
+
+// hashSlotTag is the part of key Redis Cluster hashes: the first non-empty {…}, or all of it
+func hashSlotTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+func (m *memRedis) counts(day string) map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[string]int64{}
+	for f, n := range m.hashes[pendingKey(day)] {
+		out[f] = n
+	}
+	return out
+}
+
+func (m *memRedis) pendingDays() map[string]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[string]bool{}
+	for d := range m.sets[pendingDaysKey] {
+		out[d] = true
+	}
+	return out
+}
+
+func (m *memRedis) flushingKeys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for k := range m.hashes {
+		if strings.Contains(k, ":flushing:") {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func TestTrackCountsEachViewerOncePerDay(t *testing.T) {
+	m, rdb := newMemRedis()
+	tr := NewTracker(rdb, nil)
+	ctx := context.Background()
+
+	for _, hit := range []struct {
+		kind, viewer string
+		devices      []string
+	}{
+		{KindView, "u1", []string{"d1", "d2"}},
+		{KindView, "u1", []string{"d1"}}, // same viewer, same day
+		{KindView, "u2", []string{"d1"}},
+		{KindImpression, "u1", []string{"d1"}}, // another kind counts separately
+		{KindView, "u3", nil},
+	} {
+		if err := tr.Track(ctx, hit.kind, hit.viewer, hit.devices...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	today := Day(time.Now())
+	want := map[string]int64{"d1:views": 2, "d2:views": 1, "d1:impressions": 1}
+	got := m.counts(today)
+	if len(got) != len(want) {
+		t.Errorf("got counts %v, want %v", got, want)
+	}
+	for f, n := range want {
+		if got[f] != n {
+			t.Errorf("%s = %d, want %d", f, got[f], n)
+		}
+	}
+	if !m.pendingDays()[today] {
+		t.Error("today is not in the pending days")
+	}
+}
+
+func TestFlushKeysShareASlot(t *testing.T) {
+	day := "2026-01-02"
+	if src, dst := pendingKey(day), flushingKey(day, "abc"); hashSlotTag(src) != hashSlotTag(dst) {
+		t.Errorf("%s and %s hash to different cluster slots", src, dst)
+	}
+}
+
+func TestFlushReturnsCountsWhenStoreFails(t *testing.T) {
+	m, rdb := newMemRedis()
+	db, err := sqlx.Open("postgres", "postgres://localhost:1/unused?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tr := NewTracker(rdb, db)
+	ctx := context.Background()
+
+	if err := tr.Track(ctx, KindView, "u1", "d1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Flush(ctx); err == nil {
+		t.Fatal("expected the store error")
+	}
+
+	today := Day(time.Now())
+	if got := m.counts(today); got["d1:views"] != 1 {
+		t.Errorf("counts after a failed flush: %v, want them back in the buffer", got)
+	}
+	if keys := m.flushingKeys(); len(keys) != 0 {
+		t.Errorf("claimed buffers left behind: %v", keys)
+	}
+	if !m.pendingDays()[today] {
+		t.Error("today was forgotten after a failed flush")
+	}
+}
+
+func TestFlushForgetsOldDays(t *testing.T) {
+	m, rdb := newMemRedis()
+	tr := NewTracker(rdb, nil)
+	ctx := context.Background()
+
+	// An old day whose buffer is already gone (flushed or expired), and today's
+	old, today := "2020-01-01", Day(time.Now())
+	rdb.SAdd(ctx, pendingDaysKey, old, today)
+
+	if err := tr.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	days := m.pendingDays()
+	if days[old] {
+		t.Error("an old day stays pending after its flush")
+	}
+	if !days[today] {
+		t.Error("today must stay pending: hits are still being counted")
+	}
+}
@@ -0,0 +1,245 @@
+// Package app owns the service's dependencies: connections, clients and repositories
+// are built once from config and handed to the routes, instead of package globals.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"device-service/config"
+	"device-service/internal/analytics"
+	"device-service/internal/live"
+	"device-service/internal/metrics"
+	"device-service/internal/moderation"
+	"device-service/internal/objectstore"
+	"device-service/internal/outbox"
+	"device-service/internal/repository"
+	"device-service/internal/similar"
+	"device-service/internal/trending"
+	"device-service/internal/webhook"
+	"device-service/internal/worker"
+
+	"github.com/XSAM/otelsql"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// App is one isolated instance of the service. Several can live in one process
+// (e.g. in tests), each with its own DB, Redis and storage.
+type App struct {
+	Config  *config.Config
+	DB      *sqlx.DB
+	Redis   redis.UniversalClient
+	Storage objectstore.Store
+
+	// Events receives device domain events relayed from the outbox
+	Events outbox.Sink
+
+	// Live fans device events out to the SSE streams of this instance
+	Live *live.Hub
+
+	// Views counts device views and impressions
+	Views *analytics.Tracker
+
+	// Trending recomputes the trending rankings
+	Trending *trending.Ranker
+
+	Devices    *repository.DeviceRepository
+	Favorites  *repository.FavoriteRepository
+	Webhooks   *repository.WebhookRepository
+	Moderation *repository.ModerationRepository
+	Reports    *repository.ReportRepository
+}
+
+// New connects to Postgres, Redis and Firebase Storage as configured.
+// On error everything opened so far is closed.
+func New(ctx context.Context, cfg *config.Config) (a *App, err error) {
+	var closers []func() error
+	defer func() {
+		if err != nil {
+			for i := len(closers) - 1; i >= 0; i-- {
+				_ = closers[i]()
+			}
+		}
+	}()
+
+	rdb, err := config.NewRedisClient(ctx, cfg.Redis)
+	if err != nil {
+		return nil, err
+	}
+	closers = append(closers, rdb.Close)
+
+	// otelsql оборачивает драйвер: каждый запрос получает свой span
+	sqlDB, err := otelsql.Open("postgres", cfg.Database.URL, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
+	if err != nil {
+		return nil, fmt.Errorf("open Postgres: %w", err)
+	}
+	db := sqlx.NewDb(sqlDB, "postgres")
+	closers = append(closers, db.Close)
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("connect to Postgres: %w", err)
+	}
+	slog.Info("connected to Postgres")
+
+	gcs, err := config.NewStorageClient(ctx, cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Firebase Storage ready", "bucket", cfg.Storage.Bucket)
+
+	return NewWith(cfg, db, rdb, objectstore.NewGCS(gcs, cfg.Storage.Bucket)), nil
+}
+
+// NewWith assembles an App from ready-made dependencies, e.g. an in-memory object store
+func NewWith(cfg *config.Config, db *sqlx.DB, rdb redis.UniversalClient, store objectstore.Store) *App {
+	checker := moderation.NewChecker(cfg.Moderation)
+	a := &App{
+		Config:     cfg,
+		DB:         db,
+		Redis:      rdb,
+		Storage:    store,
+		Devices:    repository.NewDeviceRepository(db, rdb, checker),
+		Favorites:  repository.NewFavoriteRepository(db),
+		Webhooks:   repository.NewWebhookRepository(db),
+		Moderation: repository.NewModerationRepository(db),
+		Reports:    repository.NewReportRepository(db, checker, cfg.Reports.HideThreshold),
+		Live:       live.NewHub(cfg.Live.Buffer),
+		Views:      analytics.NewTracker(rdb, db),
+		Trending: &trending.Ranker{
+			DB:             db,
+			Redis:          rdb,
+			Size:           cfg.Trending.Size,
+			FavoriteWeight: cfg.Trending.FavoriteWeight,
+			ViewWeight:     cfg.Trending.ViewWeight,
+			// The previous ranking stays readable while the next one is computed
+			TTL: 3 * cfg.Trending.RefreshInterval,
+		},
+	}
+
+	events := outbox.Fanout{
+		newEventSink(cfg.Outbox, rdb),
+		&outbox.RedisPubSub{Client: rdb, Channel: cfg.Live.Channel},
+		&similar.Invalidator{Redis: rdb},
+	}
+	if cfg.Webhooks.Enabled {
+		events = append(events, &webhook.Sink{Repo: a.Webhooks})
+	}
+	a.Events = events
+	return a
+}
+
+// StartWorkers launches the background loops of this instance on g
+func (a *App) StartWorkers(g *worker.Group) {
+	g.Go("business-metrics", func(ctx context.Context) {
+		metrics.RunBusinessGauges(ctx, a.DB, a.Config.Metrics.RefreshInterval)
+	})
+
+	relay := &outbox.Relay{
+		DB:           a.DB,
+		Sink:         a.Events,
+		BatchSize:    a.Config.Outbox.BatchSize,
+		PollInterval: a.Config.Outbox.PollInterval,
+		MaxBackoff:   a.Config.Outbox.MaxBackoff,
+		Retention:    a.Config.Outbox.Retention,
+	}
+	g.Go("outbox-relay", relay.Run)
+
+	g.Go("trash-purge", func(ctx context.Context) {
+		worker.Every(ctx, "trash-purge", a.Config.Trash.PurgeInterval, a.purgeTrash)
+	})
+
+	g.Go("views-flush", func(ctx context.Context) {
+		worker.Every(ctx, "views-flush", a.Config.Analytics.FlushInterval, a.Views.Flush)
+	})
+
+	g.Go("trending-refresh", func(ctx context.Context) {
+		worker.Every(ctx, "trending-refresh", a.Config.Trending.RefreshInterval, a.Trending.Refresh)
+	})
+
+	g.Go("recommendations-refresh", func(ctx context.Context) {
+		worker.Every(ctx, "recommendations-refresh", a.Config.Recommendations.RefreshInterval, a.refreshRecommendations)
+	})
+
+	g.Go("live-hub", func(ctx context.Context) {
+		a.Live.Run(ctx, a.Redis, a.Config.Live.Channel)
+	})
+
+	if a.Config.Webhooks.Enabled {
+		dispatcher := &webhook.Dispatcher{
+			Repo:         a.Webhooks,
+			Client:       webhook.NewClient(a.Config.Webhooks.Timeout, a.Config.Webhooks.AllowLoopback),
+			BatchSize:    a.Config.Webhooks.BatchSize,
+			PollInterval: a.Config.Webhooks.PollInterval,
+			MaxAttempts:  a.Config.Webhooks.MaxAttempts,
+			MaxBackoff:   a.Config.Webhooks.MaxBackoff,
+			DisableAfter: a.Config.Webhooks.DisableAfter,
+		}
+		g.Go("webhook-dispatcher", dispatcher.Run)
+	}
+}
+
+// purgeTrash removes expired devices batch by batch until none are left
+func (a *App) purgeTrash(ctx context.Context) error {
+	return inBatches(ctx, a.Config.Trash.PurgeBatch, func(ctx context.Context, limit int) (int64, error) {
+		n, err := a.Devices.PurgeDeleted(ctx, a.Config.Trash.Retention, limit)
+		if n > 0 {
+			slog.InfoContext(ctx, "purged deleted devices", "count", n)
+		}
+		return n, err
+	})
+}
+
+// inBatches calls step with batch until it handles fewer rows than that, fails or ctx ends
+func inBatches(ctx context.Context, batch int, step func(ctx context.Context, limit int) (int64, error)) error {
+	for ctx.Err() == nil {
+		n, err := step(ctx, batch)
+		if err != nil {
+			return err
+		}
+		if n < int64(batch) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// refreshRecommendations rebuilds the "favorited together" model behind /api/me/recommendations
+func (a *App) refreshRecommendations(ctx context.Context) error {
+	n, err := a.Devices.RefreshCooccurrence(ctx, a.Config.Recommendations.Neighbors, a.Config.Recommendations.MaxUserFavorites)
+	if err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "recommendation model rebuilt", "pairs", n)
+	return nil
+}
+
+func newEventSink(cfg config.OutboxConfig, rdb redis.UniversalClient) outbox.Sink {
+	switch cfg.Sink {
+	case "stdout":
+		return outbox.NewWriter(os.Stdout)
+	case "memory":
+		return &outbox.Memory{}
+	default:
+		return &outbox.RedisStream{Client: rdb, Stream: cfg.Stream, MaxLen: cfg.StreamMaxLen}
+	}
+}
+
+// Close releases every connection; call it after the HTTP server and workers have stopped
+func (a *App) Close() error {
+	var errs []error
+	if err := a.DB.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close Postgres: %w", err))
+	}
+	if err := a.Redis.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close Redis: %w", err))
+	}
+	if err := a.Storage.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close storage: %w", err))
+	}
+	return errors.Join(errs...)
+}
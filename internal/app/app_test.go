@@ -0,0 +1,184 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"device-service/config"
+	"device-service/internal/objectstore"
+	"device-service/internal/outbox"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestApp builds an App with in-memory storage and events; Postgres and Redis
+// clients are created but never connected, so only routes that don't need them work
+func newTestApp(t *testing.T, secret string) (*App, *objectstore.Memory) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Auth.JWTSecret = secret
+	cfg.Outbox.Sink = "memory"
+	cfg.Live.Buffer = 16
+	cfg.HTTP.WriteTimeout = time.Minute
+
+	db, err := sqlx.Open("postgres", "postgres://localhost:1/unused?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:1"})
+	store := objectstore.NewMemory("https://" + secret + ".example.com")
+
+	a := NewWith(cfg, db, rdb, store)
+	t.Cleanup(func() { _ = a.Close() })
+	return a, store
+}
+
+func token(t *testing.T, secret, userID string) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": userID}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func upload(t *testing.T, h http.Handler, bearer string, content []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAppsAreIsolated(t *testing.T) {
+	a, storeA := newTestApp(t, "secret-a")
+	b, storeB := newTestApp(t, "secret-b")
+	routerA, routerB := a.Router(), b.Router()
+
+	rec := upload(t, routerA, token(t, "secret-a", "user-1"), []byte("png bytes"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload to A: status %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		FileName  string `json:"fileName"`
+		PublicURL string `json:"publicUrl"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(resp.PublicURL, "https://secret-a.example.com/") {
+		t.Errorf("publicUrl %s is not from A's store", resp.PublicURL)
+	}
+	if data, ok := storeA.Get(resp.FileName); !ok || string(data) != "png bytes" {
+		t.Errorf("A's store has %q, %v", data, ok)
+	}
+	if _, ok := storeB.Get(resp.FileName); ok {
+		t.Error("the upload to A ended up in B's store")
+	}
+
+	// Each instance checks tokens with its own secret
+	if rec := upload(t, routerB, token(t, "secret-a", "user-1"), []byte("x")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("B accepted A's token: status %d", rec.Code)
+	}
+
+	// Events go to the sink of the instance that published them
+	sinkA, sinkB := memorySink(t, a), memorySink(t, b)
+	if sinkA == sinkB {
+		t.Fatal("both instances share one event sink")
+	}
+	if err := sinkA.Publish(context.Background(), outbox.Event{ID: 1, Type: outbox.DeviceCreated, AggregateID: "d1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(sinkA.Events()); got != 1 {
+		t.Errorf("A's sink has %d events, want 1", got)
+	}
+	if got := len(sinkB.Events()); got != 0 {
+		t.Errorf("B's sink has %d events, want 0", got)
+	}
+}
+
+func memorySink(t *testing.T, a *App) *outbox.Memory {
+	t.Helper()
+	fanout, ok := a.Events.(outbox.Fanout)
+	if !ok {
+		t.Fatalf("Events is %T, want outbox.Fanout", a.Events)
+	}
+	for _, s := range fanout {
+		if m, ok := s.(*outbox.Memory); ok {
+			return m
+		}
+	}
+	t.Fatal("no memory sink configured")
+	return nil
+}
+
+func TestInBatches(t *testing.T) {
+	errPurge := errors.New("purge failed")
+	tests := []struct {
+		name    string
+		results []int64 // rows handled by each call; the last call returns err
+		err     error
+		calls   int
+	}{
+		{"nothing to do", []int64{0}, nil, 1},
+		{"short batch", []int64{3}, nil, 1},
+		{"full batches", []int64{5, 5, 2}, nil, 3},
+		{"exactly full then empty", []int64{5, 0}, nil, 2},
+		{"error", []int64{5, 0}, errPurge, 2},
+	}
+	for _, tt := range tests {
+		calls := 0
+		err := inBatches(context.Background(), 5, func(_ context.Context, limit int) (int64, error) {
+			if limit != 5 {
+				t.Errorf("%s: limit %d, want 5", tt.name, limit)
+			}
+			calls++
+			if calls == len(tt.results) {
+				return tt.results[calls-1], tt.err
+			}
+			return tt.results[calls-1], nil
+		})
+		if !errors.Is(err, tt.err) || calls != tt.calls {
+			t.Errorf("%s: got %v after %d calls, want %v after %d", tt.name, err, calls, tt.err, tt.calls)
+		}
+	}
+}
+
+func TestInBatchesStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := inBatches(ctx, 5, func(context.Context, int) (int64, error) {
+		calls++
+		cancel()
+		return 5, nil
+	})
+	if err != nil || calls != 1 {
+		t.Errorf("got %v after %d calls, want nil after 1", err, calls)
+	}
+}
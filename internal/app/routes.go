@@ -0,0 +1,83 @@
+package app
+
+import (
+	"time"
+
+	"device-service/internal/handler"
+	"device-service/internal/health"
+	"device-service/internal/metrics"
+	"device-service/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Router builds the HTTP handler of this instance
+func (a *App) Router() *gin.Engine {
+	router := gin.New()
+
+	// Span на каждый запрос (имя = шаблон маршрута), W3C traceparent из входящих заголовков.
+	// Пробы и /metrics не трейсим — это шум
+	router.Use(otelgin.Middleware(a.Config.Tracing.ServiceName, otelgin.WithGinFilter(func(c *gin.Context) bool {
+		switch c.FullPath() {
+		case "/healthz", "/readyz", "/metrics":
+			return false
+		}
+		return true
+	})))
+
+	// Request ID → структурный access-лог → recovery (вместо текстового логгера gin.Default)
+	router.Use(middleware.RequestID(), middleware.AccessLog(), middleware.Recovery(), middleware.Metrics())
+
+	// GET /metrics — Prometheus (без JWT, для скрейпера)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// GET /healthz, /readyz — пробы оркестратора (без JWT)
+	checker := health.NewChecker(2 * time.Second)
+	checker.Add("postgres", health.Postgres(a.DB))
+	checker.Add("redis", health.Redis(a.Redis))
+	checker.Add("storage", a.Storage.Ping)
+	handler.RegisterHealthRoutes(router, checker)
+
+	// Группа /api + JWT middleware
+	api := router.Group("/api")
+	api.Use(middleware.JWTAuthMiddleware(a.Config.Auth.JWTSecret))
+
+	// Idempotency-Key для POST/PUT/PATCH (после JWT — ключи хранятся per user)
+	api.Use(middleware.IdempotencyMiddleware(a.Redis, a.Config.HTTP.WriteTimeout))
+
+	// Ошибки из c.Error → problem+json (после idempotency, чтобы ответ попал в replay)
+	api.Use(middleware.ErrorHandler())
+
+	// Загрузка файлов: POST /api/upload (multipart/form-data)
+	handler.RegisterUploadHandler(api, a.Storage)
+
+	// CRUD для устройств: POST/GET/PUT/PATCH/DELETE /api/devices
+	handler.RegisterDeviceRoutes(api, a.Devices, a.Views)
+
+	// SSE: GET /api/devices/events, /api/devices/:id/events
+	handler.RegisterLiveRoutes(api, a.Devices, a.Live, a.Config.Live)
+
+	// Маршруты для избранного (favorites)
+	handler.RegisterFavoriteRoutes(api, a.Favorites)
+
+	// Метаданные (категории, города, регионы, тренды)
+	handler.RegisterMetaRoutes(api, a.Devices)
+
+	// Кабинет пользователя: /api/me/...
+	handler.RegisterMeRoutes(api, a.Devices, a.Config.Trash.Retention)
+
+	// Webhook-подписки владельца и журнал доставок
+	handler.RegisterWebhookRoutes(api, a.Webhooks, a.Config.Webhooks.AllowLoopback)
+
+	// Администрирование: /api/admin/... только для role=admin
+	admin := api.Group("/admin", middleware.RequireAdmin())
+
+	// Очередь модерации и история модерации устройства
+	handler.RegisterModerationRoutes(api, admin, a.Moderation, a.Devices)
+
+	// Жалобы на объявления и их разбор
+	handler.RegisterReportRoutes(api, admin, a.Reports)
+
+	return router
+}
@@ -0,0 +1,142 @@
+// Package audit records who changed a device, how and when. Repositories call Record
+// inside the transaction of the change, so history and data can't disagree.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"device-service/internal/logging"
+
+	"github.com/goccy/go-json"
+	"github.com/jmoiron/sqlx"
+)
+
+// Actions
+const (
+	ActionCreate       = "create"
+	ActionUpdate       = "update"
+	ActionAvailability = "availability"
+	ActionDelete       = "delete"
+	ActionRestore      = "restore"
+	ActionRevert       = "revert"
+	ActionStatus       = "status"
+)
+
+// ActorSystem is the actor of changes made automatically, not by a user
+const ActorSystem = "system"
+
+// Fields that change on every write and would only clutter the diff
+var ignoredFields = map[string]bool{"version": true, "updated_at": true}
+
+// Change is the old and new value of one field
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Entry is one row of the device history
+type Entry struct {
+	ID        int64           `db:"id" json:"id"`
+	DeviceID  string          `db:"device_id" json:"device_id"`
+	Version   int64           `db:"version" json:"version"`
+	ActorID   string          `db:"actor_id" json:"actor_id"`
+	Action    string          `db:"action" json:"action"`
+	Changes   json.RawMessage `db:"changes" json:"changes"`
+	Before    json.RawMessage `db:"before" json:"before"`
+	After     json.RawMessage `db:"after" json:"after"`
+	RequestID *string         `db:"request_id" json:"request_id,omitempty"`
+	CreatedAt time.Time       `db:"created_at" json:"created_at"`
+}
+
+// Columns selects an Entry; NULL snapshots come back as JSON null
+const Columns = `id, device_id, version, actor_id, action, changes,
+    COALESCE(before, 'null') AS before, COALESCE(after, 'null') AS after, request_id, created_at`
+
+// Record appends a history entry for the device; before is nil for a create.
+// The request ID is taken from ctx.
+func Record(ctx context.Context, tx sqlx.ExecerContext, deviceID string, version int64, actorID, action string, before, after interface{}) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("audit diff: %w", err)
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO device_audit (device_id, version, actor_id, action, changes, before, after, request_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+    `, deviceID, version, actorID, action, changesJSON, beforeJSON, afterJSON, logging.RequestID(ctx))
+	if err != nil {
+		return fmt.Errorf("record %s audit entry: %w", action, err)
+	}
+	return nil
+}
+
+// Diff compares the JSON representations of two snapshots field by field
+func Diff(before, after interface{}) (map[string]Change, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+	for k, v := range to {
+		if ignoredFields[k] {
+			continue
+		}
+		if old, ok := from[k]; !ok || !reflect.DeepEqual(old, v) {
+			changes[k] = Change{From: from[k], To: v}
+		}
+	}
+	for k, old := range from {
+		if _, ok := to[k]; !ok && !ignoredFields[k] {
+			changes[k] = Change{From: old, To: nil}
+		}
+	}
+	return changes, nil
+}
+
+func fields(v interface{}) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	if isNil(v) {
+		return out, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return out, json.Unmarshal(raw, &out)
+}
+
+// snapshot encodes v, nil becomes SQL NULL
+func snapshot(v interface{}) ([]byte, error) {
+	if isNil(v) {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type snapshotFixture struct {
+	Title     string    `json:"title"`
+	Price     float64   `json:"price_per_day"`
+	Tags      []string  `json:"tags"`
+	Note      string    `json:"note,omitempty"`
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func TestDiff(t *testing.T) {
+	base := &snapshotFixture{Title: "Camera", Price: 10, Tags: []string{"a"}, Version: 1, UpdatedAt: time.Unix(0, 0)}
+	with := func(f func(s *snapshotFixture)) *snapshotFixture {
+		s := *base
+		s.Tags = append([]string(nil), base.Tags...)
+		f(&s)
+		return &s
+	}
+
+	tests := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   map[string]Change
+	}{
+		{
+			name:   "no changes",
+			before: base,
+			after:  with(func(s *snapshotFixture) {}),
+			want:   map[string]Change{},
+		},
+		{
+			name:   "changed fields",
+			before: base,
+			after:  with(func(s *snapshotFixture) { s.Title = "Drone"; s.Price = 12.5 }),
+			want: map[string]Change{
+				"title":         {From: "Camera", To: "Drone"},
+				"price_per_day": {From: 10.0, To: 12.5},
+			},
+		},
+		{
+			name:   "version and updated_at are ignored",
+			before: base,
+			after:  with(func(s *snapshotFixture) { s.Version = 2; s.UpdatedAt = time.Unix(60, 0) }),
+			want:   map[string]Change{},
+		},
+		{
+			name:   "slices compared by value",
+			before: base,
+			after:  with(func(s *snapshotFixture) { s.Tags = append(s.Tags, "b") }),
+			want:   map[string]Change{"tags": {From: []interface{}{"a"}, To: []interface{}{"a", "b"}}},
+		},
+		{
+			name:   "added field",
+			before: base,
+			after:  with(func(s *snapshotFixture) { s.Note = "new" }),
+			want:   map[string]Change{"note": {From: nil, To: "new"}},
+		},
+		{
+			name:   "removed field",
+			before: with(func(s *snapshotFixture) { s.Note = "old" }),
+			after:  base,
+			want:   map[string]Change{"note": {From: "old", To: nil}},
+		},
+		{
+			name:   "create",
+			before: (*snapshotFixture)(nil),
+			after:  &snapshotFixture{Title: "Camera"},
+			want: map[string]Change{
+				"title":         {From: nil, To: "Camera"},
+				"price_per_day": {From: nil, To: 0.0},
+				"tags":          {From: nil, To: nil},
+			},
+		},
+		{
+			name:   "untyped nil",
+			before: nil,
+			after:  nil,
+			want:   map[string]Change{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffUnencodable(t *testing.T) {
+	if _, err := Diff(nil, map[string]interface{}{"ch": make(chan int)}); err == nil {
+		t.Error("expected an error for a value JSON can't encode")
+	}
+}
@@ -0,0 +1,116 @@
+// Package domainerr defines the errors repositories and handlers return for
+// expected failures (missing entity, wrong owner, stale version, bad input).
+// middleware.ErrorHandler maps them to HTTP status codes; anything else is a 500.
+package domainerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies a domain error
+type Kind int
+
+const (
+	KindNotFound Kind = iota + 1
+	KindForbidden
+	KindConflict
+	KindPreconditionFailed
+	KindValidation
+	KindUnprocessable
+)
+
+// FieldError describes why a single input field was rejected
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error is a domain error. Message is safe to show to the client.
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// Sentinels for errors.Is checks: errors.Is(err, domainerr.ErrNotFound)
+var (
+	ErrNotFound           = &Error{Kind: KindNotFound}
+	ErrForbidden          = &Error{Kind: KindForbidden}
+	ErrConflict           = &Error{Kind: KindConflict}
+	ErrPreconditionFailed = &Error{Kind: KindPreconditionFailed}
+	ErrValidation         = &Error{Kind: KindValidation}
+	ErrUnprocessable      = &Error{Kind: KindUnprocessable}
+)
+
+func (e *Error) Error() string {
+	switch {
+	case e.Message != "" && e.Err != nil:
+		return e.Message + ": " + e.Err.Error()
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return fmt.Sprintf("domain error (kind %d)", e.Kind)
+	}
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is makes every error of the same kind match the kind's sentinel
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind
+}
+
+// NotFound is returned when the entity doesn't exist (or must look like it doesn't)
+func NotFound(format string, args ...interface{}) error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// Forbidden is returned when the entity exists but the caller may not touch it
+func Forbidden(format string, args ...interface{}) error {
+	return &Error{Kind: KindForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// Conflict is returned when the request clashes with the current state
+func Conflict(format string, args ...interface{}) error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// PreconditionFailed is returned when an If-Match version is stale
+func PreconditionFailed(format string, args ...interface{}) error {
+	return &Error{Kind: KindPreconditionFailed, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validation rejects a single input field
+func Validation(field, message string) error {
+	return &Error{
+		Kind:    KindValidation,
+		Message: field + " " + message,
+		Fields:  []FieldError{{Field: field, Message: message}},
+	}
+}
+
+// Unprocessable is returned when a well-formed request can't be applied because stored
+// data breaks the current rules, e.g. a revert to a version that no longer validates.
+// fields names the failing fields when they are known.
+func Unprocessable(fields []FieldError, format string, args ...interface{}) error {
+	return &Error{Kind: KindUnprocessable, Message: fmt.Sprintf(format, args...), Fields: fields}
+}
+
+// Invalid wraps a bind/decode error of the request body or query
+func Invalid(err error) error {
+	return &Error{Kind: KindValidation, Err: err}
+}
+
+// KindOf returns the kind of err, or 0 if it isn't a domain error
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return 0
+}
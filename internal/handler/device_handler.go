@@ -3,25 +3,31 @@
 package handler
 
 import (
-	"database/sql"
+	"device-service/internal/analytics"
+	"device-service/internal/domainerr"
 	"device-service/internal/middleware"
 	"device-service/internal/model"
+	"device-service/internal/problem"
 	"device-service/internal/repository"
-	"errors"
+	"device-service/internal/validation"
 	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 // RegisterDeviceRoutes регистрирует маршруты для CRUD операций над устройствами.
-// Ожидается, что поле image_url передаётся уже готовым (публичным) URL из Firebase Storage.
-func RegisterDeviceRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository) {
+// Ожидается, что поле image_url передаётся уже готовым (публичным) URL из Firebase Storage;
+// views считает просмотры карточек и показы в списке (для статистики владельца).
+func RegisterDeviceRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository, views *analytics.Tracker) {
 	// POST /api/devices — создаёт новое устройство
 	r.POST("/devices", func(c *gin.Context) {
 		var device model.Device
 
 		// 1) Считываем JSON из тела
-		if err := c.BindJSON(&device); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		if err := c.ShouldBindJSON(&device); err != nil {
+			c.Error(domainerr.Invalid(err))
 			return
 		}
 
@@ -32,7 +38,7 @@ func RegisterDeviceRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository)
 		// 3) Создаём устройство в БД (включая поле ImageURL)
 		err := repo.CreateDevice(c.Request.Context(), &device)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 
@@ -42,13 +48,18 @@ func RegisterDeviceRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository)
 
 	// GET /api/devices — список устройств (с фильтрами)
 	r.GET("/devices", func(c *gin.Context) {
-		filter := model.ParseDeviceFilter(c)
+		filter, err := model.ParseDeviceFilter(c)
+		if err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
 
 		devices, err := repo.GetAllDevices(c.Request.Context(), filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
+		trackViews(c, views, analytics.KindImpression, devices...)
 		c.JSON(http.StatusOK, devices)
 	})
 
@@ -58,56 +69,335 @@ func RegisterDeviceRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository)
 
 		device, err := repo.GetDeviceByID(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+			c.Error(err)
+			return
+		}
+		if !canView(c, device) {
+			c.Error(domainerr.NotFound("device not found"))
+			return
+		}
+		trackViews(c, views, analytics.KindView, *device)
+
+		// ETag = версия устройства; If-None-Match → 304
+		etag := deviceETag(device.Version)
+		c.Header("ETag", etag)
+		if notModified(c, etag) {
+			c.Status(http.StatusNotModified)
 			return
 		}
 		c.JSON(http.StatusOK, device)
 	})
 
+	// GET /api/devices/:id/similar?limit=10 — похожие опубликованные устройства других владельцев:
+	// та же категория, город/регион, близкая цена, похожие название и описание
+	r.GET("/devices/:id/similar", func(c *gin.Context) {
+		var query struct {
+			Limit int `form:"limit,default=10" binding:"min=1,max=50"`
+		}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		device, err := repo.GetDeviceByID(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if !canView(c, device) {
+			c.Error(domainerr.NotFound("device not found"))
+			return
+		}
+
+		devices, err := repo.GetSimilarDevices(c.Request.Context(), device, query.Limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, devices)
+	})
+
 	// PUT /api/devices/:id — обновить устройство (в том числе можно обновить image_url)
+	// If-Match: "<version>" — необязательная проверка версии, при несовпадении 412
 	r.PUT("/devices/:id", func(c *gin.Context) {
 		id := c.Param("id")
 		userID, _ := middleware.GetUserID(c)
 
+		expectedVersion, err := ifMatchVersion(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
 		var device model.Device
 		if err := c.ShouldBindJSON(&device); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			c.Error(domainerr.Invalid(err))
 			return
 		}
 
 		device.ID = id
 		device.OwnerID = userID
+		device.Version = expectedVersion
 
-		err := repo.UpdateDevice(c.Request.Context(), &device)
+		err = repo.UpdateDevice(c.Request.Context(), &device)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Not found or no permission"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			}
+			c.Error(err)
 			return
 		}
 
+		c.Header("ETag", deviceETag(device.Version))
 		c.JSON(http.StatusOK, gin.H{"message": "Device updated successfully"})
 	})
 
-	// DELETE /api/devices/:id — удалить устройство
+	// PATCH /api/devices/:id — частичное обновление (RFC 7396 JSON Merge Patch).
+	// Меняются только переданные поля, null очищает поле. Без If-Match
+	// версия берётся из прочитанного устройства, так что параллельная запись даст 412.
+	r.PATCH("/devices/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		userID, _ := middleware.GetUserID(c)
+
+		if ct := c.ContentType(); ct != MergePatchContentType && ct != "application/json" {
+			problem.Write(c, http.StatusUnsupportedMediaType, "Content-Type must be "+MergePatchContentType)
+			return
+		}
+
+		expectedVersion, err := ifMatchVersion(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		patch, err := c.GetRawData()
+		if err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		current, err := repo.GetDeviceByID(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if current.OwnerID != userID {
+			c.Error(domainerr.Forbidden("device belongs to another user"))
+			return
+		}
+		if expectedVersion != 0 && expectedVersion != current.Version {
+			c.Error(domainerr.PreconditionFailed("device was modified, current version is %d", current.Version))
+			return
+		}
+
+		// Накладываем патч на текущее JSON-представление устройства
+		doc, err := json.Marshal(current)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		merged, err := applyMergePatch(doc, patch)
+		if err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		var device model.Device
+		if err := decodePatched(merged, &device); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+		if err := validation.Struct(&device); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		// Служебные поля клиент не меняет
+		device.ID = current.ID
+		device.OwnerID = current.OwnerID
+		device.CreatedAt = current.CreatedAt
+		device.Version = current.Version
+
+		if err := repo.UpdateDevice(c.Request.Context(), &device); err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("ETag", deviceETag(device.Version))
+		c.JSON(http.StatusOK, device)
+	})
+
+	// DELETE /api/devices/:id — переместить устройство в корзину (см. /api/me/devices/trash)
 	r.DELETE("/devices/:id", func(c *gin.Context) {
 		id := c.Param("id")
 		userID, _ := middleware.GetUserID(c)
 
-		err := repo.DeleteDevice(c.Request.Context(), id, userID)
+		expectedVersion, err := ifMatchVersion(c)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Not found or no permission"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			}
+			c.Error(err)
+			return
+		}
+
+		err = repo.DeleteDevice(c.Request.Context(), id, userID, expectedVersion)
+		if err != nil {
+			c.Error(err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Device deleted successfully"})
 	})
 
+	// POST /api/devices/:id/restore — вернуть устройство из корзины
+	r.POST("/devices/:id/restore", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		device, err := repo.RestoreDevice(c.Request.Context(), c.Param("id"), userID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.Header("ETag", deviceETag(device.Version))
+		c.JSON(http.StatusOK, device)
+	})
+
+	// GET /api/devices/:id/history?limit=50&before_id= — журнал изменений, новые сначала.
+	// Доступен владельцу и администраторам (role=admin), в том числе для устройства в корзине
+	r.GET("/devices/:id/history", func(c *gin.Context) {
+		var query struct {
+			Limit    int   `form:"limit,default=50" binding:"min=1,max=500"`
+			BeforeID int64 `form:"before_id" binding:"min=0"`
+		}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		id := c.Param("id")
+		userID, _ := middleware.GetUserID(c)
+		ownerID, err := repo.GetDeviceOwner(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if ownerID != userID && !middleware.IsAdmin(c) {
+			c.Error(domainerr.Forbidden("device belongs to another user"))
+			return
+		}
+
+		entries, err := repo.GetHistory(c.Request.Context(), id, query.Limit, query.BeforeID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	})
+
+	// GET /api/devices/:id/stats?from=2024-01-01&to=2024-01-31 — просмотры, показы, избранное
+	// и конверсия по дням (UTC); по умолчанию последние 30 дней. Владельцу и role=admin
+	r.GET("/devices/:id/stats", func(c *gin.Context) {
+		var query struct {
+			From time.Time `form:"from" time_format:"2006-01-02" time_utc:"1"`
+			To   time.Time `form:"to" time_format:"2006-01-02" time_utc:"1"`
+		}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+		if query.To.IsZero() {
+			query.To = time.Now().UTC().Truncate(24 * time.Hour)
+		}
+		if query.From.IsZero() {
+			query.From = query.To.AddDate(0, 0, -29)
+		}
+		switch {
+		case query.From.After(query.To):
+			c.Error(domainerr.Validation("from", "must not be after to"))
+			return
+		case query.To.Sub(query.From) > maxStatsRange:
+			c.Error(domainerr.Validation("from", "must be at most 365 days before to"))
+			return
+		}
+
+		id := c.Param("id")
+		userID, _ := middleware.GetUserID(c)
+		ownerID, err := repo.GetDeviceOwner(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if ownerID != userID && !middleware.IsAdmin(c) {
+			c.Error(domainerr.Forbidden("device belongs to another user"))
+			return
+		}
+
+		days, err := repo.GetDailyStats(c.Request.Context(), id, query.From, query.To)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		report := model.StatsReport{
+			DeviceID: id,
+			From:     analytics.Day(query.From),
+			To:       analytics.Day(query.To),
+			Days:     days,
+		}
+		for _, d := range days {
+			report.Totals.Views += d.Views
+			report.Totals.Impressions += d.Impressions
+			report.Totals.FavoritesAdded += d.FavoritesAdded
+			report.Totals.FavoritesRemoved += d.FavoritesRemoved
+		}
+		report.Totals.SetConversion()
+		c.JSON(http.StatusOK, report)
+	})
+
+	// POST /api/devices/:id/revert — вернуть поля устройства к версии из истории.
+	// Это обычная запись: версия растёт, If-Match необязателен
+	r.POST("/devices/:id/revert", func(c *gin.Context) {
+		var input struct {
+			Version int64 `json:"version" binding:"required,min=1"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		expectedVersion, err := ifMatchVersion(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		userID, _ := middleware.GetUserID(c)
+		device, err := repo.RevertDevice(c.Request.Context(), c.Param("id"), userID, input.Version, expectedVersion)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.Header("ETag", deviceETag(device.Version))
+		c.JSON(http.StatusOK, device)
+	})
+
+	// POST /api/devices/:id/{submit,withdraw,pause,resume,archive,unarchive} — переходы
+	// статуса объявления владельцем (см. model.StatusTransitions); публикует модератор.
+	// If-Match: "<version>" — необязательная проверка версии
+	for _, t := range model.StatusTransitions {
+		t := t
+		r.POST("/devices/:id/"+t.Action, func(c *gin.Context) {
+			expectedVersion, err := ifMatchVersion(c)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+
+			userID, _ := middleware.GetUserID(c)
+			device, err := repo.TransitionStatus(c.Request.Context(), c.Param("id"), userID, t, expectedVersion)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			c.Header("ETag", deviceETag(device.Version))
+			c.JSON(http.StatusOK, device)
+		})
+	}
+
 	// PATCH /api/devices/:id/availability — обновить доступность
 	type AvailabilityUpdate struct {
 		Available bool `json:"available"`
@@ -116,21 +406,24 @@ func RegisterDeviceRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository)
 		id := c.Param("id")
 		userID, _ := middleware.GetUserID(c)
 
+		expectedVersion, err := ifMatchVersion(c)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
 		var input AvailabilityUpdate
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			c.Error(domainerr.Invalid(err))
 			return
 		}
 
-		err := repo.UpdateAvailability(c.Request.Context(), id, userID, input.Available)
+		version, err := repo.UpdateAvailability(c.Request.Context(), id, userID, input.Available, expectedVersion)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Not found or no permission"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			}
+			c.Error(err)
 			return
 		}
+		c.Header("ETag", deviceETag(version))
 		c.JSON(http.StatusOK, gin.H{"message": "Availability updated"})
 	})
 
@@ -139,9 +432,49 @@ func RegisterDeviceRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository)
 		deviceID := c.Param("id")
 		device, err := repo.GetDeviceByID(c.Request.Context(), deviceID)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+			c.Error(err)
+			return
+		}
+		if !canView(c, device) {
+			c.Error(domainerr.NotFound("device not found"))
+			return
+		}
+
+		etag := deviceETag(device.Version)
+		c.Header("ETag", etag)
+		if notModified(c, etag) {
+			c.Status(http.StatusNotModified)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"available": device.Available})
 	})
 }
+
+// canView reports whether the caller may see the device: published devices are
+// public, the others only to their owner and admins
+func canView(c *gin.Context, device *model.Device) bool {
+	if device.Status == model.StatusPublished {
+		return true
+	}
+	userID, _ := middleware.GetUserID(c)
+	return device.OwnerID == userID || middleware.IsAdmin(c)
+}
+
+// Longest period GET /api/devices/:id/stats returns: to may be at most 365 days after
+// from (366 days of stats, both ends included). Keep the error message in sync.
+const maxStatsRange = 365 * 24 * time.Hour
+
+// trackViews counts views of published devices by anyone but their owner.
+// Statistics must never break the page, so failures are only logged.
+func trackViews(c *gin.Context, views *analytics.Tracker, kind string, devices ...model.Device) {
+	userID, _ := middleware.GetUserID(c)
+	ids := make([]string, 0, len(devices))
+	for _, d := range devices {
+		if d.Status == model.StatusPublished && d.OwnerID != userID {
+			ids = append(ids, d.ID)
+		}
+	}
+	if err := views.Track(c.Request.Context(), kind, userID, ids...); err != nil {
+		slog.WarnContext(c.Request.Context(), "failed to track device views", "kind", kind, "error", err)
+	}
+}
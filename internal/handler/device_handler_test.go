@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"device-service/internal/middleware"
+	"device-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deviceRouter registers the device routes without a repository: only requests
+// rejected before the repository is called can be served
+func deviceRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/api", func(c *gin.Context) { c.Set(middleware.UserIDKey, "owner") }, middleware.ErrorHandler())
+	RegisterDeviceRoutes(api, nil, nil)
+	return r
+}
+
+func TestStatusTransitionRoutes(t *testing.T) {
+	r := deviceRouter()
+	for _, tr := range model.StatusTransitions {
+		req := httptest.NewRequest(http.MethodPost, "/api/devices/d1/"+tr.Action, nil)
+		req.Header.Set("If-Match", `W/"1"`)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s with a weak If-Match: got %d, want 400", tr.Action, rec.Code)
+		}
+	}
+
+	// Publishing is not an owner action
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/devices/d1/publish", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("POST publish: got %d, want 404", rec.Code)
+	}
+}
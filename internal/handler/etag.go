@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"device-service/internal/domainerr"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidIfMatch = domainerr.Validation("If-Match", "must contain a single ETag returned by GET /api/devices/:id")
+
+// deviceETag builds the strong ETag for a device version
+func deviceETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion parses the If-Match header into the device version the client expects.
+// Returns 0 when the header is absent or "*", which means "any version".
+func ifMatchVersion(c *gin.Context) (int64, error) {
+	h := strings.TrimSpace(c.GetHeader("If-Match"))
+	if h == "" || h == "*" {
+		return 0, nil
+	}
+	if strings.Contains(h, ",") || strings.HasPrefix(h, "W/") {
+		// If-Match uses strong comparison, weak tags never match
+		return 0, errInvalidIfMatch
+	}
+	v, err := strconv.ParseInt(strings.Trim(h, `"`), 10, 64)
+	if err != nil || v < 1 {
+		return 0, errInvalidIfMatch
+	}
+	return v, nil
+}
+
+// notModified reports whether If-None-Match matches etag (weak comparison, RFC 9110 §13.1.2)
+func notModified(c *gin.Context, etag string) bool {
+	h := c.GetHeader("If-None-Match")
+	if h == "" {
+		return false
+	}
+	for _, tag := range strings.Split(h, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func contextWithHeader(name, value string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if value != "" {
+		c.Request.Header.Set(name, value)
+	}
+	return c
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int64
+		wantErr bool
+	}{
+		{header: "", want: 0},
+		{header: "*", want: 0},
+		{header: `"7"`, want: 7},
+		{header: ` "12" `, want: 12},
+		{header: "3", want: 3},
+		{header: `W/"7"`, wantErr: true},
+		{header: `"7", "8"`, wantErr: true},
+		{header: `"0"`, wantErr: true},
+		{header: `"-1"`, wantErr: true},
+		{header: `"abc"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ifMatchVersion(contextWithHeader("If-Match", tt.header))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("If-Match %q: expected an error, got %d", tt.header, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("If-Match %q: unexpected error %v", tt.header, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("If-Match %q: got %d, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := deviceETag(5)
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: `"5"`, want: true},
+		{header: `W/"5"`, want: true},
+		{header: `"4", "5"`, want: true},
+		{header: `"4",W/"5"`, want: true},
+		{header: "*", want: true},
+		{header: `"4"`, want: false},
+		{header: "5", want: false},
+	}
+	for _, tt := range tests {
+		if got := notModified(contextWithHeader("If-None-Match", tt.header), etag); got != tt.want {
+			t.Errorf("If-None-Match %q: got %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
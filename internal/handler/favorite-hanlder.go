@@ -3,10 +3,11 @@
 package handler
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"device-service/internal/middleware"
+	"device-service/internal/problem"
 	"device-service/internal/repository"
 
 	"github.com/gin-gonic/gin"
@@ -19,15 +20,15 @@ func RegisterFavoriteRoutes(r *gin.RouterGroup, favRepo *repository.FavoriteRepo
 		// Извлекаем userID из контекста (JWT)
 		userID, ok := middleware.GetUserID(c)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			problem.Write(c, http.StatusUnauthorized, "user not authenticated")
 			return
 		}
 		deviceID := c.Param("id")
 
-		log.Printf("➕ AddFavorite called by user=%s for device=%s", userID, deviceID)
+		slog.InfoContext(c.Request.Context(), "add favorite", "user_id", userID, "device_id", deviceID)
 
 		if err := favRepo.AddFavorite(c.Request.Context(), userID, deviceID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 		// Успешно добавлено, возвращаем 204 No Content
@@ -38,14 +39,14 @@ func RegisterFavoriteRoutes(r *gin.RouterGroup, favRepo *repository.FavoriteRepo
 	r.GET("/devices/favorite", func(c *gin.Context) {
 		userID, ok := middleware.GetUserID(c)
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			problem.Write(c, http.StatusUnauthorized, "user not authenticated")
 			return
 		}
 
-		log.Printf("🔎 GetFavorites called by user=%s", userID)
+		slog.DebugContext(c.Request.Context(), "list favorites", "user_id", userID)
 		devices, err := favRepo.GetFavorites(c.Request.Context(), userID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 		c.JSON(http.StatusOK, devices)
@@ -57,12 +58,8 @@ func RegisterFavoriteRoutes(r *gin.RouterGroup, favRepo *repository.FavoriteRepo
 		deviceID := c.Param("id")
 
 		if err := favRepo.RemoveFavorite(c.Request.Context(), userID, deviceID); err != nil {
-			// Если ошибки “not found” — возвращаем 404, иначе 500
-			if err.Error() == "not found" {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Favorite not found"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			}
+			// Нет такого избранного → 404 (маппинг в middleware.ErrorHandler)
+			c.Error(err)
 			return
 		}
 		c.Status(http.StatusNoContent)
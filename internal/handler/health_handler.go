@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+
+	"device-service/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterHealthRoutes registers the orchestrator probes outside of /api (no JWT):
+// GET /healthz — the process is alive; GET /readyz — dependencies are reachable.
+func RegisterHealthRoutes(r gin.IRoutes, checker *health.Checker) {
+	// GET /healthz — liveness, never touches dependencies
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+	})
+
+	// GET /readyz — readiness, 503 if any dependency is down
+	r.GET("/readyz", func(c *gin.Context) {
+		report := checker.Run(c.Request.Context())
+
+		status := http.StatusOK
+		if report.Status != health.StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	})
+}
@@ -0,0 +1,129 @@
+// internal/handler/live_handler.go
+
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"device-service/config"
+	"device-service/internal/domainerr"
+	"device-service/internal/live"
+	"device-service/internal/middleware"
+	"device-service/internal/model"
+	"device-service/internal/outbox"
+	"device-service/internal/repository"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterLiveRoutes регистрирует потоки Server-Sent Events с изменениями устройств.
+// Событие SSE: id — id события outbox, event — его тип (device.updated, ...), data — payload.
+func RegisterLiveRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository, hub *live.Hub, cfg config.LiveConfig) {
+	// GET /api/devices/events?category=&city=&region= — изменения в каталоге (с фильтрами)
+	r.GET("/devices/events", func(c *gin.Context) {
+		var q struct {
+			Category string `form:"category" binding:"omitempty,device_category"`
+			City     string `form:"city" binding:"max=100"`
+			Region   string `form:"region" binding:"max=100"`
+		}
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		// Только каталог: опубликованные устройства и снятие с публикации
+		sub := hub.Subscribe(func(ev live.Event) bool {
+			listed := ev.Device.Status == model.StatusPublished || ev.PreviousStatus == model.StatusPublished
+			return listed && (q.Category == "" || ev.Device.Category == q.Category) &&
+				(q.City == "" || strings.EqualFold(ev.Device.City, q.City)) &&
+				(q.Region == "" || strings.EqualFold(ev.Device.Region, q.Region))
+		})
+		defer sub.Close()
+
+		streamEvents(c, sub, cfg, nil)
+	})
+
+	// GET /api/devices/:id/events — изменения одного устройства; поток закрывается после device.deleted,
+	// а у не-владельца — и после снятия с публикации
+	r.GET("/devices/:id/events", func(c *gin.Context) {
+		device, err := repo.GetDeviceByID(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if !canView(c, device) {
+			c.Error(domainerr.NotFound("device not found"))
+			return
+		}
+
+		// Остальные видят устройство, пока оно опубликовано: снятие с публикации — последнее событие
+		userID, _ := middleware.GetUserID(c)
+		privileged := device.OwnerID == userID || middleware.IsAdmin(c)
+		sub := hub.Subscribe(func(ev live.Event) bool {
+			return ev.AggregateID == device.ID && deviceEventVisible(ev, privileged)
+		})
+		defer sub.Close()
+
+		streamEvents(c, sub, cfg, func(ev live.Event) bool {
+			return ev.Type == outbox.DeviceDeleted || (!privileged && ev.Device.Status != model.StatusPublished)
+		})
+	})
+}
+
+// deviceEventVisible reports whether a viewer of a device stream gets ev. The owner and
+// admins see every change; others only those of the listed device and its unlisting.
+func deviceEventVisible(ev live.Event, privileged bool) bool {
+	return privileged || ev.Device.Status == model.StatusPublished || ev.PreviousStatus == model.StatusPublished
+}
+
+// streamEvents writes the subscription as SSE until the client leaves, an event for
+// which last returns true has been sent, the subscription is closed (the subscriber
+// fell behind or the server is shutting down) or cfg.MaxStreamDuration passes.
+func streamEvents(c *gin.Context, sub *live.Subscription, cfg config.LiveConfig, last func(live.Event) bool) {
+	// Поток живёт дольше WriteTimeout сервера
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx: не буферизовать
+	c.Render(http.StatusOK, sse.Event{Event: "ready", Data: "{}", Retry: 3000})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(cfg.Heartbeat)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(cfg.MaxStreamDuration)
+	defer deadline.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-heartbeat.C:
+			// Комментарий SSE: держит соединение через прокси, клиенту не виден
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case ev, ok := <-sub.C:
+			if !ok {
+				return // клиент отстал или сервер останавливается — переподключится и перечитает состояние
+			}
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatInt(ev.ID, 10),
+				Event: ev.Type,
+				Data:  string(ev.Payload),
+			})
+			c.Writer.Flush()
+			if last != nil && last(ev) {
+				return
+			}
+		}
+	}
+}
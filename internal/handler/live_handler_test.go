@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"device-service/config"
+	"device-service/internal/live"
+	"device-service/internal/model"
+	"device-service/internal/outbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+func statusEvent(id int64, typ, status, previous string) live.Event {
+	ev := live.Event{Event: outbox.Event{ID: id, Type: typ, AggregateID: "d1", Payload: []byte(`{}`)}}
+	ev.Device.Status = status
+	ev.PreviousStatus = previous
+	return ev
+}
+
+func TestDeviceEventVisible(t *testing.T) {
+	tests := []struct {
+		name       string
+		ev         live.Event
+		privileged bool
+		want       bool
+	}{
+		{"published update", statusEvent(1, outbox.DeviceUpdated, model.StatusPublished, ""), false, true},
+		{"unlisting", statusEvent(1, outbox.DeviceStatusChanged, model.StatusPaused, model.StatusPublished), false, true},
+		{"draft update", statusEvent(1, outbox.DeviceUpdated, model.StatusDraft, ""), false, false},
+		{"archived to draft", statusEvent(1, outbox.DeviceStatusChanged, model.StatusDraft, model.StatusArchived), false, false},
+		{"draft update for owner", statusEvent(1, outbox.DeviceUpdated, model.StatusDraft, ""), true, true},
+	}
+	for _, tt := range tests {
+		if got := deviceEventVisible(tt.ev, tt.privileged); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// runStream serves streamEvents in the background; the returned func waits for it to
+// return and gives the written body
+func runStream(t *testing.T, sub *live.Subscription, last func(live.Event) bool) func() string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/devices/d1/events", nil).WithContext(ctx)
+	cfg := config.LiveConfig{Heartbeat: time.Hour, MaxStreamDuration: time.Hour}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		streamEvents(c, sub, cfg, last)
+	}()
+	return func() string {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream did not end")
+		}
+		return rec.Body.String()
+	}
+}
+
+func TestStreamEndsAfterLastEvent(t *testing.T) {
+	hub := live.NewHub(8)
+	sub := hub.Subscribe(nil)
+	defer sub.Close()
+	wait := runStream(t, sub, func(ev live.Event) bool { return ev.Device.Status != model.StatusPublished })
+
+	hub.Broadcast(statusEvent(1, outbox.DeviceUpdated, model.StatusPublished, ""))
+	hub.Broadcast(statusEvent(2, outbox.DeviceStatusChanged, model.StatusPaused, model.StatusPublished))
+	hub.Broadcast(statusEvent(3, outbox.DeviceUpdated, model.StatusPaused, ""))
+
+	body := wait()
+	if !strings.Contains(body, "id:1\n") || !strings.Contains(body, "id:2\n") {
+		t.Errorf("events before the unlisting are missing:\n%s", body)
+	}
+	if strings.Contains(body, "id:3\n") {
+		t.Errorf("stream went on after the last event:\n%s", body)
+	}
+}
+
+func TestStreamEndsWhenHubCloses(t *testing.T) {
+	hub := live.NewHub(8)
+	sub := hub.Subscribe(nil)
+	defer sub.Close()
+	wait := runStream(t, sub, nil)
+
+	hub.Close()
+	if body := wait(); !strings.Contains(body, "event:ready") {
+		t.Errorf("got body %q", body)
+	}
+}
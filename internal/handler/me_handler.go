@@ -0,0 +1,85 @@
+// internal/handler/me_handler.go
+
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/middleware"
+	"device-service/internal/model"
+	"device-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrashedDevice is a device in the trash with the time it will be purged
+type TrashedDevice struct {
+	model.Device
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// RegisterMeRoutes регистрирует маршруты текущего пользователя: /api/me/...
+// retention — сколько устройство хранится в корзине.
+func RegisterMeRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository, retention time.Duration) {
+	// GET /api/me/devices — все устройства владельца в любом статусе (кроме корзины)
+	// с фильтрами DeviceFilter, ?status= и статистикой для кабинета
+	r.GET("/me/devices", func(c *gin.Context) {
+		filter, err := model.ParseOwnerDeviceFilter(c)
+		if err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		userID, _ := middleware.GetUserID(c)
+		devices, err := repo.GetOwnerDevices(c.Request.Context(), userID, filter)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, devices)
+	})
+
+	// GET /api/me/recommendations?limit=20 — устройства, которые добавляли в избранное люди
+	// с похожим избранным; если истории мало — популярные (source показывает, откуда устройство)
+	r.GET("/me/recommendations", func(c *gin.Context) {
+		var query struct {
+			Limit int `form:"limit,default=20" binding:"min=1,max=100"`
+		}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		userID, _ := middleware.GetUserID(c)
+		recs, err := repo.GetRecommendations(c.Request.Context(), userID, query.Limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, recs)
+	})
+
+	// GET /api/me/devices/trash — удалённые устройства, которые ещё можно восстановить
+	r.GET("/me/devices/trash", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		devices, err := repo.GetTrash(c.Request.Context(), userID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, trashEntries(devices, retention))
+	})
+}
+
+// trashEntries adds to each trashed device the time PurgeDeleted removes it
+func trashEntries(devices []model.Device, retention time.Duration) []TrashedDevice {
+	trash := make([]TrashedDevice, 0, len(devices))
+	for _, d := range devices {
+		trash = append(trash, TrashedDevice{Device: d, PurgeAt: d.DeletedAt.Add(retention)})
+	}
+	return trash
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"device-service/internal/model"
+)
+
+func TestTrashEntries(t *testing.T) {
+	deleted := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	devices := []model.Device{{ID: "d1", DeletedAt: &deleted}}
+
+	trash := trashEntries(devices, 30*24*time.Hour)
+	if len(trash) != 1 || trash[0].ID != "d1" {
+		t.Fatalf("got %+v", trash)
+	}
+	if want := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC); !trash[0].PurgeAt.Equal(want) {
+		t.Errorf("purge_at = %s, want %s", trash[0].PurgeAt, want)
+	}
+
+	// An empty trash is a JSON array, not null
+	if trash := trashEntries(nil, time.Hour); trash == nil {
+		t.Error("got nil for an empty trash")
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"io"
+
+	"github.com/goccy/go-json"
+)
+
+// MergePatchContentType is the media type of RFC 7396 JSON Merge Patch documents
+const MergePatchContentType = "application/merge-patch+json"
+
+var (
+	errPatchNotObject = errors.New("merge patch must be a JSON object")
+	errTrailingData   = errors.New("unexpected data after the JSON document")
+)
+
+// applyMergePatch applies an RFC 7396 merge patch to the JSON document doc.
+// Members set to null are removed, objects are merged recursively,
+// everything else replaces the target value.
+func applyMergePatch(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := decodeJSON(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := decodeJSON(patch, &p); err != nil {
+		return nil, err
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return nil, errPatchNotObject
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// decodeJSON keeps numbers as json.Number so prices and versions survive the round trip
+// unchanged. data must hold exactly one JSON document.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errTrailingData
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errTrailingData
+	}
+	return nil
+}
+
+// decodePatched decodes a merged document into v with encoding/json: its type errors
+// name the JSON field (e.g. price_per_day), which validation.Problem reports per field
+func decodePatched(doc []byte, v interface{}) error {
+	return stdjson.Unmarshal(doc, v)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{name: "replace member", doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{name: "add member", doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{name: "null removes member", doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{name: "null for missing member", doc: `{"a":"b"}`, patch: `{"x":null}`, want: `{"a":"b"}`},
+		{name: "nested merge", doc: `{"a":{"b":"c","d":"e"}}`, patch: `{"a":{"b":"x","d":null}}`, want: `{"a":{"b":"x"}}`},
+		{name: "object replaces scalar", doc: `{"a":"b"}`, patch: `{"a":{"c":"d"}}`, want: `{"a":{"c":"d"}}`},
+		{name: "nested null in new object dropped", doc: `{}`, patch: `{"a":{"b":null,"c":1}}`, want: `{"a":{"c":1}}`},
+		{name: "arrays are replaced", doc: `{"a":[1,2,3]}`, patch: `{"a":[4]}`, want: `{"a":[4]}`},
+		{name: "empty patch", doc: `{"a":"b"}`, patch: `{}`, want: `{"a":"b"}`},
+		{name: "numbers kept exact", doc: `{"price":12345678901234567890.10}`, patch: `{"title":"x"}`, want: `{"price":12345678901234567890.10,"title":"x"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyMergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !jsonEqual(t, got, []byte(tt.want)) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyMergePatchRejectsNonObject(t *testing.T) {
+	for _, patch := range []string{`[1,2]`, `"a"`, `null`, `42`} {
+		if _, err := applyMergePatch([]byte(`{"a":"b"}`), []byte(patch)); !errors.Is(err, errPatchNotObject) {
+			t.Errorf("patch %s: got %v, want errPatchNotObject", patch, err)
+		}
+	}
+	if _, err := applyMergePatch([]byte(`{"a":"b"}`), []byte(`{"a":`)); err == nil {
+		t.Error("malformed patch: expected an error")
+	}
+}
+
+func TestApplyMergePatchRejectsTrailingData(t *testing.T) {
+	for _, patch := range []string{
+		`{"name":"x"} garbage`,
+		`{"name":"x"}{"name":"y"}`,
+		`{"name":"x"} null`,
+		`{"name":"x"}]`,
+	} {
+		if _, err := applyMergePatch([]byte(`{"name":"a"}`), []byte(patch)); err == nil {
+			t.Errorf("patch %s: expected an error", patch)
+		}
+	}
+	// Surrounding whitespace is fine
+	if _, err := applyMergePatch([]byte(`{"name":"a"}`), []byte(" {\"name\":\"x\"}\n\t")); err != nil {
+		t.Errorf("patch with trailing whitespace: %v", err)
+	}
+}
+
+// jsonEqual compares two documents ignoring member order; numbers are compared by their text
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	return bytes.Equal(canonical(t, a), canonical(t, b))
+}
+
+func canonical(t *testing.T, data []byte) []byte {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
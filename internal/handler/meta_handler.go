@@ -1,9 +1,10 @@
 package handler
 
 import (
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
 	"device-service/internal/repository"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,7 +14,7 @@ func RegisterMetaRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository) {
 	r.GET("/categories", func(c *gin.Context) {
 		cats, err := repo.GetCategories(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 		c.JSON(http.StatusOK, cats)
@@ -23,7 +24,7 @@ func RegisterMetaRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository) {
 	r.GET("/cities", func(c *gin.Context) {
 		cities, err := repo.GetCities(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 		c.JSON(http.StatusOK, cities)
@@ -33,23 +34,22 @@ func RegisterMetaRoutes(r *gin.RouterGroup, repo *repository.DeviceRepository) {
 	r.GET("/regions", func(c *gin.Context) {
 		regions, err := repo.GetRegions(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 		c.JSON(http.StatusOK, regions)
 	})
 
-	// GET /api/devices/trending?limit=10
+	// GET /api/devices/trending?window=7d&category=&city=&limit=10
 	r.GET("/devices/trending", func(c *gin.Context) {
-		// optional limit param
-		limitStr := c.DefaultQuery("limit", "10")
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil || limit < 1 {
-			limit = 10
+		q, err := model.ParseTrendingQuery(c)
+		if err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
 		}
-		devices, err := repo.GetTrendingDevices(c.Request.Context(), limit)
+		devices, err := repo.GetTrendingDevices(c.Request.Context(), q)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Error(err)
 			return
 		}
 		c.JSON(http.StatusOK, devices)
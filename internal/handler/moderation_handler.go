@@ -0,0 +1,103 @@
+// internal/handler/moderation_handler.go
+
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/middleware"
+	"device-service/internal/model"
+	"device-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterModerationRoutes регистрирует очередь модерации (admin — группа только для role=admin)
+// и историю модерации устройства для владельца.
+func RegisterModerationRoutes(r, admin *gin.RouterGroup, repo *repository.ModerationRepository, devices *repository.DeviceRepository) {
+	// GET /api/admin/moderation?status=pending&flagged=true&limit=50 — очередь: помеченные первыми, затем старые
+	admin.GET("/moderation", func(c *gin.Context) {
+		var query struct {
+			Status  string `form:"status,default=pending" binding:"oneof=pending approved rejected changes_requested withdrawn"`
+			Flagged bool   `form:"flagged"`
+			Limit   int    `form:"limit,default=50" binding:"min=1,max=500"`
+		}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		entries, err := repo.ListQueue(c.Request.Context(), query.Status, query.Flagged, query.Limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	})
+
+	// POST /api/admin/moderation/:itemId/approve — опубликовать (или принять правку)
+	admin.POST("/moderation/:itemId/approve", func(c *gin.Context) {
+		decide(c, repo, model.ModerationApproved, "")
+	})
+
+	// POST /api/admin/moderation/:itemId/reject {"reason": "..."} — отклонить, устройство архивируется
+	admin.POST("/moderation/:itemId/reject", func(c *gin.Context) {
+		var input model.ModerationDecision
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+		decide(c, repo, model.ModerationRejected, input.Reason)
+	})
+
+	// POST /api/admin/moderation/:itemId/request-changes {"reason": "..."} — вернуть владельцу в черновик
+	admin.POST("/moderation/:itemId/request-changes", func(c *gin.Context) {
+		var input model.ModerationDecision
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+		decide(c, repo, model.ModerationChangesRequested, input.Reason)
+	})
+
+	// GET /api/devices/:id/moderation — решения модераторов по устройству (владелец и role=admin)
+	r.GET("/devices/:id/moderation", func(c *gin.Context) {
+		id := c.Param("id")
+		userID, _ := middleware.GetUserID(c)
+		ownerID, err := devices.GetDeviceOwner(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if ownerID != userID && !middleware.IsAdmin(c) {
+			c.Error(domainerr.Forbidden("device belongs to another user"))
+			return
+		}
+
+		items, err := repo.GetDeviceModeration(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, items)
+	})
+}
+
+// decide applies a moderator's decision to the item in the path
+func decide(c *gin.Context, repo *repository.ModerationRepository, decision, reason string) {
+	itemID, err := strconv.ParseInt(c.Param("itemId"), 10, 64)
+	if err != nil {
+		c.Error(domainerr.NotFound("moderation item not found"))
+		return
+	}
+
+	moderatorID, _ := middleware.GetUserID(c)
+	item, err := repo.Decide(c.Request.Context(), itemID, moderatorID, decision, reason)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
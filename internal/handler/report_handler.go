@@ -0,0 +1,89 @@
+// internal/handler/report_handler.go
+
+package handler
+
+import (
+	"net/http"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/middleware"
+	"device-service/internal/model"
+	"device-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterReportRoutes регистрирует жалобы пользователей на объявления
+// и их разбор администраторами (admin — группа только для role=admin).
+func RegisterReportRoutes(r, admin *gin.RouterGroup, repo *repository.ReportRepository) {
+	// POST /api/devices/:id/report — пожаловаться на объявление (одна открытая жалоба на пользователя)
+	r.POST("/devices/:id/report", func(c *gin.Context) {
+		var input model.ReportInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		userID, _ := middleware.GetUserID(c)
+		report, err := repo.CreateReport(c.Request.Context(), c.Param("id"), userID, input)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, report)
+	})
+
+	// GET /api/admin/reports?limit=50 — устройства с открытыми жалобами, самые обжалованные первыми
+	admin.GET("/reports", func(c *gin.Context) {
+		var query struct {
+			Limit int `form:"limit,default=50" binding:"min=1,max=500"`
+		}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		reported, err := repo.ListReportedDevices(c.Request.Context(), query.Limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, reported)
+	})
+
+	// GET /api/admin/devices/:id/reports?status=open — жалобы на устройство
+	admin.GET("/devices/:id/reports", func(c *gin.Context) {
+		var query struct {
+			Status string `form:"status" binding:"omitempty,oneof=open upheld dismissed"`
+		}
+		if err := c.ShouldBindQuery(&query); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		reports, err := repo.GetDeviceReports(c.Request.Context(), c.Param("id"), query.Status)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, reports)
+	})
+
+	// POST /api/admin/devices/:id/reports/resolve {"resolution": "upheld|dismissed", "note": "..."}
+	// upheld — объявление архивируется, dismissed — скрытое жалобами возвращается в каталог
+	admin.POST("/devices/:id/reports/resolve", func(c *gin.Context) {
+		var input model.ReportResolution
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		adminID, _ := middleware.GetUserID(c)
+		n, err := repo.ResolveReports(c.Request.Context(), c.Param("id"), adminID, input.Resolution, input.Note)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"resolved": n})
+	})
+}
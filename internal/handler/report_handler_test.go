@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"device-service/internal/middleware"
+	"device-service/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The repository is nil: every request below must be rejected before reaching it
+func TestReportRoutesValidateInput(t *testing.T) {
+	if err := validation.Init(); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/api", func(c *gin.Context) { c.Set(middleware.UserIDKey, "u1") }, middleware.ErrorHandler())
+	RegisterReportRoutes(api, api.Group("/admin"), nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"unknown reason", http.MethodPost, "/api/devices/d1/report", `{"reason":"ugly"}`},
+		{"no reason", http.MethodPost, "/api/devices/d1/report", `{"comment":"bad"}`},
+		{"long comment", http.MethodPost, "/api/devices/d1/report", `{"reason":"scam","comment":"` + strings.Repeat("x", 2001) + `"}`},
+		{"unknown resolution", http.MethodPost, "/api/admin/devices/d1/reports/resolve", `{"resolution":"open"}`},
+		{"no resolution", http.MethodPost, "/api/admin/devices/d1/reports/resolve", `{"note":"ok"}`},
+		{"unknown status", http.MethodGet, "/api/admin/devices/d1/reports?status=closed", ""},
+		{"limit too large", http.MethodGet, "/api/admin/reports?limit=501", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400: %s", tt.name, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// internal/handler/webhook_handler.go
+
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/middleware"
+	"device-service/internal/model"
+	"device-service/internal/repository"
+	"device-service/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWebhookRoutes регистрирует управление webhook-подписками владельца.
+// Подписка получает события об устройствах своего владельца (см. outbox.EventTypes).
+// allowLoopback разрешает адреса 127.0.0.1/localhost — только для локальных тестов.
+func RegisterWebhookRoutes(r *gin.RouterGroup, repo *repository.WebhookRepository, allowLoopback bool) {
+	// POST /api/webhooks — создать подписку; secret возвращается только здесь и при ротации
+	r.POST("/webhooks", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		var in model.WebhookInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+		if err := checkWebhookURL(in.URL, allowLoopback); err != nil {
+			c.Error(err)
+			return
+		}
+
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		sub := model.WebhookSubscription{
+			OwnerID:    userID,
+			URL:        in.URL,
+			EventTypes: in.EventTypes,
+			Secret:     secret,
+		}
+		if err := repo.CreateSubscription(c.Request.Context(), &sub); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, sub)
+	})
+
+	// GET /api/webhooks — подписки текущего пользователя
+	r.GET("/webhooks", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		subs, err := repo.ListSubscriptions(c.Request.Context(), userID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, subs)
+	})
+
+	// GET /api/webhooks/:id
+	r.GET("/webhooks/:id", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		sub, err := repo.GetSubscription(c.Request.Context(), c.Param("id"), userID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, sub)
+	})
+
+	// PUT /api/webhooks/:id — изменить URL/события; "active": true включает отключённую подписку
+	r.PUT("/webhooks/:id", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		var in model.WebhookInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+		if err := checkWebhookURL(in.URL, allowLoopback); err != nil {
+			c.Error(err)
+			return
+		}
+
+		sub, err := repo.UpdateSubscription(c.Request.Context(), c.Param("id"), userID, in)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, sub)
+	})
+
+	// DELETE /api/webhooks/:id — удалить подписку вместе с журналом доставок
+	r.DELETE("/webhooks/:id", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		if err := repo.DeleteSubscription(c.Request.Context(), c.Param("id"), userID); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// POST /api/webhooks/:id/rotate-secret — новый секрет подписи
+	r.POST("/webhooks/:id/rotate-secret", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		sub, err := repo.RotateSecret(c.Request.Context(), c.Param("id"), userID, secret)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, sub)
+	})
+
+	// GET /api/webhooks/:id/deliveries?limit=50 — журнал доставок, новые сначала
+	r.GET("/webhooks/:id/deliveries", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		var q struct {
+			Limit int `form:"limit,default=50" binding:"min=1,max=500"`
+		}
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.Error(domainerr.Invalid(err))
+			return
+		}
+
+		sub, err := repo.GetSubscription(c.Request.Context(), c.Param("id"), userID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		deliveries, err := repo.ListDeliveries(c.Request.Context(), sub.ID, q.Limit)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, deliveries)
+	})
+
+	// POST /api/webhooks/:id/deliveries/:deliveryId/replay — отправить доставку ещё раз
+	r.POST("/webhooks/:id/deliveries/:deliveryId/replay", func(c *gin.Context) {
+		userID, _ := middleware.GetUserID(c)
+
+		deliveryID, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+		if err != nil {
+			c.Error(domainerr.NotFound("delivery not found"))
+			return
+		}
+
+		sub, err := repo.GetSubscription(c.Request.Context(), c.Param("id"), userID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		delivery, err := repo.ReplayDelivery(c.Request.Context(), sub.ID, deliveryID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusAccepted, delivery)
+	})
+}
+
+// checkWebhookURL accepts only absolute http(s) URLs outside internal networks
+func checkWebhookURL(raw string, allowLoopback bool) error {
+	if err := webhook.CheckURL(raw, allowLoopback); err != nil {
+		return domainerr.Validation("url", err.Error())
+	}
+	return nil
+}
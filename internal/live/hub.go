@@ -0,0 +1,166 @@
+// Package live streams device changes to connected clients. Every replica subscribes
+// to the Redis pub/sub channel the outbox relay publishes to, and the Hub fans the
+// events out to the SSE streams open on that replica.
+package live
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"device-service/internal/metrics"
+	"device-service/internal/outbox"
+
+	"github.com/goccy/go-json"
+	"github.com/redis/go-redis/v9"
+)
+
+// Event is a device event as seen by stream subscribers
+type Event struct {
+	outbox.Event
+	Device outbox.DeviceRef // decoded from Payload, for filtering
+
+	// PreviousStatus is the status before a device.status_changed event, so streams
+	// of the public catalog also see a device being unlisted
+	PreviousStatus string
+}
+
+// Filter decides whether a subscriber gets an event
+type Filter func(Event) bool
+
+// Subscription receives matching events on C until it is closed. C is closed when
+// the subscriber can't keep up or the hub shuts down: the client should reconnect and reload.
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	filter Filter
+	hub    *Hub
+	once   sync.Once
+}
+
+// Close unsubscribes; safe to call more than once
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// Hub fans events out to the subscriptions of this replica
+type Hub struct {
+	buffer int
+
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewHub creates a hub whose subscribers may lag behind by up to buffer events
+func NewHub(buffer int) *Hub {
+	return &Hub{buffer: buffer, subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe registers a subscriber for events matching filter
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	ch := make(chan Event, h.buffer)
+	s := &Subscription{C: ch, ch: ch, filter: filter, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		s.once.Do(func() { close(ch) })
+		return s
+	}
+	h.subs[s] = struct{}{}
+	metrics.LiveSubscribers.Set(float64(len(h.subs)))
+	return s
+}
+
+// Close ends every subscription and those made later, so open streams return
+// on shutdown instead of holding the server until their deadline
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	subs := make([]*Subscription, 0, len(h.subs))
+	for s := range h.subs {
+		subs = append(subs, s)
+	}
+	h.mu.Unlock()
+
+	for _, s := range subs {
+		h.remove(s)
+	}
+}
+
+func (h *Hub) remove(s *Subscription) {
+	s.once.Do(func() {
+		h.mu.Lock()
+		delete(h.subs, s)
+		n := len(h.subs)
+		h.mu.Unlock()
+
+		close(s.ch)
+		metrics.LiveSubscribers.Set(float64(n))
+	})
+}
+
+// Broadcast delivers ev to every matching subscriber without blocking
+func (h *Hub) Broadcast(ev Event) {
+	var lagging []*Subscription
+
+	h.mu.RLock()
+	for s := range h.subs {
+		if s.filter != nil && !s.filter(ev) {
+			continue
+		}
+		select {
+		case s.ch <- ev:
+		default:
+			lagging = append(lagging, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range lagging {
+		metrics.LiveDropped.Inc()
+		h.remove(s)
+	}
+}
+
+// Run listens on the Redis channel and broadcasts device events until ctx is cancelled.
+// go-redis resubscribes by itself after a connection loss.
+func (h *Hub) Run(ctx context.Context, rdb redis.UniversalClient, channel string) {
+	pubsub := rdb.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	msgs := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(msg.Payload), &ev.Event); err != nil {
+				slog.WarnContext(ctx, "malformed live event", "error", err)
+				continue
+			}
+			// Favorites carry the user who favorited; they are not for public streams
+			if !strings.HasPrefix(ev.Type, "device.") {
+				continue
+			}
+			if err := json.Unmarshal(ev.Payload, &ev.Device); err != nil {
+				slog.WarnContext(ctx, "malformed live event payload", "event_id", ev.ID, "error", err)
+				continue
+			}
+			if ev.Type == outbox.DeviceStatusChanged {
+				var p outbox.StatusPayload
+				if err := json.Unmarshal(ev.Payload, &p); err == nil {
+					ev.PreviousStatus = p.From
+				}
+			}
+			h.Broadcast(ev)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package live
+
+import (
+	"testing"
+
+	"device-service/internal/outbox"
+)
+
+func event(id int64, deviceID string) Event {
+	return Event{Event: outbox.Event{ID: id, Type: outbox.DeviceUpdated, AggregateID: deviceID}}
+}
+
+func TestBroadcastFilters(t *testing.T) {
+	h := NewHub(4)
+	all := h.Subscribe(nil)
+	defer all.Close()
+	d1 := h.Subscribe(func(ev Event) bool { return ev.AggregateID == "d1" })
+	defer d1.Close()
+
+	h.Broadcast(event(1, "d1"))
+	h.Broadcast(event(2, "d2"))
+
+	if got := len(all.C); got != 2 {
+		t.Errorf("unfiltered subscriber got %d events, want 2", got)
+	}
+	if got := len(d1.C); got != 1 {
+		t.Fatalf("filtered subscriber got %d events, want 1", got)
+	}
+	if ev := <-d1.C; ev.ID != 1 {
+		t.Errorf("filtered subscriber got event %d, want 1", ev.ID)
+	}
+}
+
+func TestLaggingSubscriberIsClosed(t *testing.T) {
+	h := NewHub(1)
+	sub := h.Subscribe(nil)
+	defer sub.Close()
+
+	h.Broadcast(event(1, "d1"))
+	h.Broadcast(event(2, "d1")) // buffer full
+
+	if ev, ok := <-sub.C; !ok || ev.ID != 1 {
+		t.Fatalf("got %v, %v, want the buffered event", ev.ID, ok)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("a lagging subscription must be closed")
+	}
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	h := NewHub(4)
+	before := h.Subscribe(nil)
+	h.Close()
+	after := h.Subscribe(nil)
+
+	for name, sub := range map[string]*Subscription{"before": before, "after": after} {
+		if _, ok := <-sub.C; ok {
+			t.Errorf("subscription made %s Close is still open", name)
+		}
+		sub.Close() // still safe
+	}
+	h.Broadcast(event(1, "d1"))
+}
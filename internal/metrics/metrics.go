@@ -0,0 +1,176 @@
+// Package metrics defines the Prometheus metrics of the service and the /metrics handler.
+// Everything is registered on the default registry, so Go runtime and process metrics come for free.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "device_service"
+
+// Cache names used as the "cache" label of CacheRequests
+const (
+	CacheDevicesList    = "devices_list"
+	CacheSimilarDevices = "similar_devices"
+)
+
+var (
+	// HTTPRequests counts handled requests per route template (not raw path, to keep cardinality low)
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration is the request latency per route template
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// CacheRequests counts Redis cache lookups by result: hit, miss or error
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Redis cache lookups by cache and result (hit, miss, error).",
+	}, []string{"cache", "result"})
+
+	// UploadSize is the size distribution of files uploaded via POST /api/upload
+	UploadSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upload_size_bytes",
+		Help:      "Size of uploaded files.",
+		Buckets:   prometheus.ExponentialBuckets(16*1024, 2, 12), // 16KiB .. 32MiB
+	})
+
+	// UploadBytes is the total number of bytes written to storage
+	UploadBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes written to object storage by uploads.",
+	})
+
+	// OutboxEvents counts outbox relay deliveries by sink and result: published or error
+	OutboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_events_total",
+		Help:      "Domain events relayed from the outbox by sink and result (published, error).",
+	}, []string{"sink", "result"})
+
+	// WebhookDeliveries counts webhook attempts by result: succeeded, retry or failed (gave up)
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by result (succeeded, retry, failed).",
+	}, []string{"result"})
+
+	// LiveSubscribers is the number of open SSE streams on this replica
+	LiveSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "live_subscribers",
+		Help:      "Open Server-Sent Events streams.",
+	})
+
+	// LiveDropped counts streams closed because the client could not keep up
+	LiveDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "live_dropped_subscribers_total",
+		Help:      "SSE streams closed because the client fell behind.",
+	})
+
+	// ModerationFlags counts flags raised by the automatic moderation checks, by check
+	ModerationFlags = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "moderation_flags_total",
+		Help:      "Listings flagged by automatic moderation checks, by check.",
+	}, []string{"check"})
+
+	// DeviceReports counts listing reports filed by users, by reason
+	DeviceReports = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "device_reports_total",
+		Help:      "Listing reports filed by users, by reason.",
+	}, []string{"reason"})
+
+	// DevicesByCategory is refreshed periodically by RunBusinessGauges
+	DevicesByCategory = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "devices",
+		Help:      "Number of devices by category.",
+	}, []string{"category"})
+
+	// Favorites is the total number of favorites, refreshed by RunBusinessGauges
+	Favorites = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "favorites",
+		Help:      "Number of favorites across all users.",
+	})
+)
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDBStats exports connection pool stats (sql.DBStats) of db as go_sql_* metrics
+func RegisterDBStats(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RunBusinessGauges recomputes the business gauges every interval until ctx is done.
+// The queries run in the background so a scrape never waits on Postgres.
+func RunBusinessGauges(ctx context.Context, db *sqlx.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := refreshBusinessGauges(ctx, db); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "failed to refresh business metrics", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func refreshBusinessGauges(ctx context.Context, db *sqlx.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var rows []struct {
+		Category string `db:"category"`
+		Count    int64  `db:"count"`
+	}
+	if err := db.SelectContext(ctx, &rows,
+		`SELECT category, COUNT(*) AS count FROM devices WHERE deleted_at IS NULL GROUP BY category`); err != nil {
+		return err
+	}
+
+	var favorites int64
+	if err := db.GetContext(ctx, &favorites, `SELECT COUNT(*) FROM favorites`); err != nil {
+		return err
+	}
+
+	// Reset so categories that disappeared stop being reported
+	DevicesByCategory.Reset()
+	for _, row := range rows {
+		DevicesByCategory.WithLabelValues(row.Category).Set(float64(row.Count))
+	}
+	Favorites.Set(float64(favorites))
+	return nil
+}
@@ -3,9 +3,10 @@ package middleware
 import (
 	"errors"
 	"net/http"
-	"os"
 	"strings"
 
+	"device-service/internal/problem"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -14,57 +15,56 @@ const (
 	// Context key under which we store the user’s UUID
 	UserIDKey = "userID"
 
-	// Fallback secret — in prod you should ALWAYS set JWT_SECRET instead
-	JwtSecret = "verylongrandomstringyouwritehere-and-never-commit-an-obvious-password"
-)
+	// Context key under which we store the "role" claim
+	RoleKey = "role"
 
-// JWT_SECRET env var name
-const EnvJWTSecret = "JWT_SECRET"
+	// RoleAdmin is the "role" claim value of operators (moderation, audit)
+	RoleAdmin = "admin"
+)
 
-// JWTAuthMiddleware validates the token and pulls the "sub" claim into context.
-func JWTAuthMiddleware() gin.HandlerFunc {
+// JWTAuthMiddleware validates the HMAC-signed token with secret (config.Auth.JWTSecret)
+// and pulls the "sub" and optional "role" claims into context.
+func JWTAuthMiddleware(secret string) gin.HandlerFunc {
+	key := []byte(secret)
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid Authorization header"})
+			problem.Abort(c, http.StatusUnauthorized, "Missing or invalid Authorization header")
 			return
 		}
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Load secret from env or fallback
-		secret := []byte(os.Getenv(EnvJWTSecret))
-		if len(secret) == 0 {
-			secret = []byte(JwtSecret)
-		}
-
 		// Parse & validate token
 		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, errors.New("unexpected signing method")
 			}
-			return secret, nil
+			return key, nil
 		})
 		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			problem.Abort(c, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
 		// Extract claims and pull out the subject
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			problem.Abort(c, http.StatusUnauthorized, "Invalid token claims")
 			return
 		}
 
 		sub, ok := claims["sub"].(string)
 		if !ok || sub == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "sub claim not found in token"})
+			problem.Abort(c, http.StatusUnauthorized, "sub claim not found in token")
 			return
 		}
 
 		// Store the user's UUID in context
 		c.Set(UserIDKey, sub)
+		if role, ok := claims["role"].(string); ok {
+			c.Set(RoleKey, role)
+		}
 		c.Next()
 	}
 }
@@ -79,3 +79,20 @@ func GetUserID(c *gin.Context) (string, bool) {
 	userID, ok := v.(string)
 	return userID, ok
 }
+
+// IsAdmin reports whether the token carries role=admin
+func IsAdmin(c *gin.Context) bool {
+	return c.GetString(RoleKey) == RoleAdmin
+}
+
+// RequireAdmin rejects requests without role=admin with 403.
+// Must be registered after JWTAuthMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) {
+			problem.Abort(c, http.StatusForbidden, "Admin role required")
+			return
+		}
+		c.Next()
+	}
+}
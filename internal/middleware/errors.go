@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/problem"
+	"device-service/internal/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the last error a handler attached with c.Error as a problem+json response.
+// Domain errors map to 400/403/404/409/412/422, anything else is logged and reported as a bare 500.
+// Register it after IdempotencyMiddleware so the rendered error is what gets stored for replay.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		problem.Render(c, problemFor(c, c.Errors.Last().Err))
+	}
+}
+
+func problemFor(c *gin.Context, err error) *problem.Details {
+	var de *domainerr.Error
+	if !errors.As(err, &de) {
+		slog.ErrorContext(c.Request.Context(), "internal error",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"error", err,
+		)
+		return problem.New(http.StatusInternalServerError, "Internal server error")
+	}
+
+	switch de.Kind {
+	case domainerr.KindNotFound:
+		return problem.New(http.StatusNotFound, de.Message)
+	case domainerr.KindForbidden:
+		return problem.New(http.StatusForbidden, de.Message)
+	case domainerr.KindConflict:
+		return problem.New(http.StatusConflict, de.Message)
+	case domainerr.KindPreconditionFailed:
+		return problem.New(http.StatusPreconditionFailed, de.Message)
+	case domainerr.KindValidation:
+		if len(de.Fields) > 0 {
+			return problem.Validation(problemFields(de.Fields))
+		}
+		if de.Err != nil {
+			return validation.Problem(de.Err)
+		}
+		return problem.New(http.StatusBadRequest, de.Message)
+	case domainerr.KindUnprocessable:
+		p := problem.New(http.StatusUnprocessableEntity, de.Message)
+		if len(de.Fields) > 0 {
+			p.Type = problem.TypeValidation
+			p.Errors = problemFields(de.Fields)
+		}
+		return p
+	default:
+		return problem.New(http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+func problemFields(fields []domainerr.FieldError) []problem.FieldError {
+	out := make([]problem.FieldError, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, problem.FieldError{Field: f.Field, Message: f.Message})
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProblemFor(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	fields := []domainerr.FieldError{{Field: "category", Message: "must be one of: phones"}}
+
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		typ        string
+		wantFields int
+	}{
+		{"not found", domainerr.NotFound("device not found"), http.StatusNotFound, "about:blank", 0},
+		{"forbidden", domainerr.Forbidden("no"), http.StatusForbidden, "about:blank", 0},
+		{"conflict", domainerr.Conflict("busy"), http.StatusConflict, "about:blank", 0},
+		{"precondition", domainerr.PreconditionFailed("stale"), http.StatusPreconditionFailed, "about:blank", 0},
+		{"validation field", domainerr.Validation("name", "is required"), http.StatusBadRequest, problem.TypeValidation, 1},
+		{"unprocessable with fields", domainerr.Unprocessable(fields, "version 2 no longer passes validation"), http.StatusUnprocessableEntity, problem.TypeValidation, 1},
+		{"unprocessable", domainerr.Unprocessable(nil, "device violates constraint"), http.StatusUnprocessableEntity, "about:blank", 0},
+		{"internal", errors.New("connection reset"), http.StatusInternalServerError, "about:blank", 0},
+	}
+	for _, tt := range tests {
+		p := problemFor(c, tt.err)
+		if p.Status != tt.status || p.Type != tt.typ || len(p.Errors) != tt.wantFields {
+			t.Errorf("%s: got %d %s with %d fields, want %d %s with %d", tt.name,
+				p.Status, p.Type, len(p.Errors), tt.status, tt.typ, tt.wantFields)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"device-service/internal/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// IdempotencyKeyHeader is the header clients send to make a write safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses served from the idempotency store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// How long a completed response is kept for replay
+	idempotencyTTL = 24 * time.Hour
+
+	// The lock outlives the longest request by this much, so the response can be stored
+	idempotencyLockMargin = 30 * time.Second
+
+	// Bodies are buffered in memory to fingerprint them
+	maxIdempotentBodySize = 1 << 20
+
+	// Multipart uploads are spooled to a temporary file instead (see spoolBody)
+	maxIdempotentUploadSize = 32 << 20
+
+	maxIdempotencyKeyLen = 255
+)
+
+// replayedHeaders are stored with the response and sent again on replay
+var replayedHeaders = []string{"ETag", "Location"}
+
+// idempotencyRecord is what we keep in Redis under idempotency:{userID}:{key}.
+// While the first request is in flight Done is false and only Fingerprint is set.
+type idempotencyRecord struct {
+	Fingerprint string            `json:"fingerprint"`
+	Done        bool              `json:"done"`
+	Status      int               `json:"status,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// IdempotencyMiddleware stores the first response of a POST/PUT/PATCH request carrying
+// an Idempotency-Key header and replays it for retries with the same key.
+// A retry with the same key but a different method, path or body is rejected with 422.
+// Must be registered after JWTAuthMiddleware, keys are scoped per user.
+// requestTimeout is the longest a request may take (the server's WriteTimeout): a key
+// stays locked a little longer than that, so a slow first request is never run twice.
+func IdempotencyMiddleware(store redis.UniversalClient, requestTimeout time.Duration) gin.HandlerFunc {
+	lockTTL := requestTimeout + idempotencyLockMargin
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			problem.Abort(c, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		userID, _ := GetUserID(c)
+
+		// Read the body so we can fingerprint it, then put it back for the handler
+		var bodySum [sha256.Size]byte
+		if isMultipart(c.Request) {
+			spool, err := os.CreateTemp("", "idempotent-upload-*")
+			if err != nil {
+				problem.Abort(c, http.StatusInternalServerError, "cannot buffer request body")
+				return
+			}
+			defer func() {
+				spool.Close()
+				os.Remove(spool.Name())
+			}()
+			if bodySum, err = spoolBody(spool, http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentUploadSize)); err != nil {
+				abortUnreadableBody(c, err)
+				return
+			}
+			c.Request.Body = io.NopCloser(spool)
+		} else {
+			body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+			if err != nil {
+				abortUnreadableBody(c, err)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			bodySum = sha256.Sum256(body)
+		}
+
+		fingerprint := requestFingerprint(c.Request, bodySum)
+		redisKey := fmt.Sprintf("idempotency:%s:%s", userID, key)
+
+		// The response must be saved even if the client has already gone away
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		lock, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+		acquired, err := store.SetNX(ctx, redisKey, lock, lockTTL).Result()
+		if err != nil {
+			// Redis is down: serve the request without idempotency rather than failing it
+			slog.WarnContext(ctx, "idempotency store unavailable, serving without it",
+				"idempotency_key", key, "error", err)
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			replayIdempotent(c, store, redisKey, fingerprint)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		status := rec.Status()
+		if status >= http.StatusInternalServerError {
+			// Let the client retry a failed request with the same key
+			_ = store.Del(ctx, redisKey).Err()
+			return
+		}
+
+		headers := map[string]string{}
+		for _, h := range replayedHeaders {
+			if v := rec.Header().Get(h); v != "" {
+				headers[h] = v
+			}
+		}
+		done, _ := json.Marshal(idempotencyRecord{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Headers:     headers,
+			Body:        rec.body.Bytes(),
+		})
+		if err := store.Set(ctx, redisKey, done, idempotencyTTL).Err(); err != nil {
+			slog.ErrorContext(ctx, "failed to store idempotent response",
+				"idempotency_key", key, "error", err)
+		}
+	}
+}
+
+// replayIdempotent answers a request whose key is already known
+func replayIdempotent(c *gin.Context, store redis.UniversalClient, redisKey, fingerprint string) {
+	raw, err := store.Get(c.Request.Context(), redisKey).Bytes()
+	if err != nil {
+		// The lock expired between SETNX and GET — ask the client to retry
+		problem.Abort(c, http.StatusConflict, "Request with this Idempotency-Key is being processed, retry later")
+		return
+	}
+
+	var rec idempotencyRecord
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		problem.Abort(c, http.StatusInternalServerError, "corrupted idempotency record")
+		return
+	}
+
+	if rec.Fingerprint != fingerprint {
+		problem.Abort(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+		return
+	}
+	if !rec.Done {
+		problem.Abort(c, http.StatusConflict, "Request with this Idempotency-Key is being processed, retry later")
+		return
+	}
+
+	for h, v := range rec.Headers {
+		c.Header(h, v)
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	if len(rec.Body) == 0 {
+		c.AbortWithStatus(rec.Status)
+		return
+	}
+	c.Data(rec.Status, rec.ContentType, rec.Body)
+	c.Abort()
+}
+
+// requestFingerprint identifies a request by method, URI and the SHA-256 of its body
+func requestFingerprint(r *http.Request, bodySum [sha256.Size]byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(bodySum[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// spoolBody copies body into f while hashing it, so an upload is fingerprinted by its
+// content without being held in memory, and rewinds f for the handler
+func spoolBody(f *os.File, body io.Reader) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), body); err != nil {
+		return sum, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// abortUnreadableBody answers a request whose body could not be read for the fingerprint
+func abortUnreadableBody(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		problem.Abort(c, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("request body with an Idempotency-Key must not exceed %d bytes", tooLarge.Limit))
+		return
+	}
+	problem.Abort(c, http.StatusBadRequest, "cannot read request body")
+}
+
+func isMultipart(r *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "multipart/")
+}
+
+// responseRecorder tees everything the handler writes so it can be stored for replay
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
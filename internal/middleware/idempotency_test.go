@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeStore implements the few commands the middleware uses; any other call panics
+type fakeStore struct {
+	redis.UniversalClient
+
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{data: map[string]string{}}
+}
+
+func (s *fakeStore) SetNX(ctx context.Context, key string, value interface{}, _ time.Duration) *redis.BoolCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; ok {
+		return redis.NewBoolResult(false, nil)
+	}
+	s.data[key] = string(value.([]byte))
+	return redis.NewBoolResult(true, nil)
+}
+
+func (s *fakeStore) Set(ctx context.Context, key string, value interface{}, _ time.Duration) *redis.StatusCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = string(value.([]byte))
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (s *fakeStore) Get(ctx context.Context, key string) *redis.StringCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(v, nil)
+}
+
+func (s *fakeStore) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range keys {
+		delete(s.data, k)
+	}
+	return redis.NewIntResult(int64(len(keys)), nil)
+}
+
+// idempotentRouter serves POST /items with handler behind the middleware, as user u1
+func idempotentRouter(store redis.UniversalClient, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(UserIDKey, "u1") })
+	r.Use(IdempotencyMiddleware(store, time.Minute))
+	r.POST("/items", handler)
+	return r
+}
+
+func post(r http.Handler, key, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/items", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func postJSON(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	return post(r, key, "application/json", []byte(body))
+}
+
+// echoHandler answers 201 with the request body and counts its calls
+func echoHandler(calls *atomic.Int32) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		calls.Add(1)
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Header("ETag", `"1"`)
+		c.Header("X-Handler", "echo")
+		c.Data(http.StatusCreated, "application/json", body)
+	}
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotentRouter(newFakeStore(), echoHandler(&calls))
+
+	first := postJSON(r, "k1", `{"name":"drill"}`)
+	second := postJSON(r, "k1", `{"name":"drill"}`)
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls.Load())
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay: got %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" || second.Header().Get("ETag") != `"1"` {
+		t.Errorf("replay headers: %v", second.Header())
+	}
+	if second.Header().Get("X-Handler") != "" {
+		t.Error("headers outside replayedHeaders must not be replayed")
+	}
+
+	// Without a key, or with another key, the handler runs again
+	postJSON(r, "", `{"name":"drill"}`)
+	postJSON(r, "k2", `{"name":"drill"}`)
+	if calls.Load() != 3 {
+		t.Errorf("handler ran %d times, want 3", calls.Load())
+	}
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotentRouter(newFakeStore(), echoHandler(&calls))
+
+	postJSON(r, "k1", `{"name":"drill"}`)
+	if rec := postJSON(r, "k1", `{"name":"saw"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got %d, want 422", rec.Code)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("handler ran %d times, want 1", calls.Load())
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	r := idempotentRouter(newFakeStore(), func(c *gin.Context) {
+		close(entered)
+		<-release
+		c.Status(http.StatusNoContent)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postJSON(r, "k1", `{}`) }()
+	<-entered
+
+	if rec := postJSON(r, "k1", `{}`); rec.Code != http.StatusConflict {
+		t.Errorf("concurrent retry: got %d, want 409", rec.Code)
+	}
+	close(release)
+	if rec := <-done; rec.Code != http.StatusNoContent {
+		t.Errorf("first request: got %d, want 204", rec.Code)
+	}
+	if rec := postJSON(r, "k1", `{}`); rec.Code != http.StatusNoContent || rec.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry after completion: got %d, want a replayed 204", rec.Code)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotentRouter(newFakeStore(), func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	if rec := postJSON(r, "k1", `{}`); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first attempt: got %d", rec.Code)
+	}
+	if rec := postJSON(r, "k1", `{}`); rec.Code != http.StatusCreated || rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry after 5xx: got %d, want the handler to run again", rec.Code)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("handler ran %d times, want 2", calls.Load())
+	}
+}
+
+func TestIdempotencyBodyTooLarge(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotentRouter(newFakeStore(), echoHandler(&calls))
+
+	body := `{"name":"` + strings.Repeat("x", maxIdempotentBodySize) + `"}`
+	if rec := postJSON(r, "k1", body); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got %d, want 413", rec.Code)
+	}
+	if calls.Load() != 0 {
+		t.Error("handler must not run")
+	}
+}
+
+func multipartBody(t *testing.T, content string) (string, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.SetBoundary("fixed-boundary"); err != nil {
+		t.Fatal(err)
+	}
+	fw, err := mw.CreateFormFile("file", "photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	return mw.FormDataContentType(), buf.Bytes()
+}
+
+func TestIdempotencyMultipartUsesContent(t *testing.T) {
+	var calls atomic.Int32
+	var received []string
+	r := idempotentRouter(newFakeStore(), func(c *gin.Context) {
+		calls.Add(1)
+		fh, err := c.FormFile("file")
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		f, _ := fh.Open()
+		data, _ := io.ReadAll(f)
+		f.Close()
+		received = append(received, string(data))
+		c.String(http.StatusOK, string(data))
+	})
+
+	ct, first := multipartBody(t, "aaaa")
+	_, same := multipartBody(t, "aaaa")
+	_, other := multipartBody(t, "bbbb") // same size, different content
+
+	if rec := post(r, "k1", ct, first); rec.Code != http.StatusOK || rec.Body.String() != "aaaa" {
+		t.Fatalf("upload: got %d %q", rec.Code, rec.Body)
+	}
+	if rec := post(r, "k1", ct, same); rec.Code != http.StatusOK || rec.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("same upload: got %d, want a replay", rec.Code)
+	}
+	if rec := post(r, "k1", ct, other); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different file of the same size: got %d, want 422", rec.Code)
+	}
+	if calls.Load() != 1 || len(received) != 1 || received[0] != "aaaa" {
+		t.Errorf("handler ran %d times with %q, want once with the uploaded file", calls.Load(), received)
+	}
+}
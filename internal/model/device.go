@@ -1,16 +1,54 @@
 package model
 
+import "time"
+
+// DeviceCategories is the closed list of categories a device can be listed under.
+// The devices_category_check constraint (migrations/011) must list the same values.
+var DeviceCategories = []string{
+	"phones",
+	"laptops",
+	"tablets",
+	"cameras",
+	"audio",
+	"gaming",
+	"drones",
+	"tv",
+	"appliances",
+	"tools",
+	"other",
+}
+
+// IsDeviceCategory reports whether c is one of DeviceCategories
+func IsDeviceCategory(c string) bool {
+	for _, v := range DeviceCategories {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Device is a rentable listing. `binding` tags are the validation rules
+// applied to create/update requests (see internal/validation).
 type Device struct {
 	ID          string  `db:"id" json:"id"`
-	Name        string  `db:"name" json:"name"`
-	Description string  `db:"description" json:"description"`
-	Category    string  `db:"category" json:"category"`
-	PricePerDay float64 `db:"price_per_day" json:"price_per_day"`
+	Name        string  `db:"name" json:"name" binding:"required,notblank,max=200"`
+	Description string  `db:"description" json:"description" binding:"max=5000"`
+	Category    string  `db:"category" json:"category" binding:"required,device_category"`
+	PricePerDay float64 `db:"price_per_day" json:"price_per_day" binding:"gt=0,lte=1000000"`
 	Available   bool    `db:"available" json:"available"`
-	ImageURL    string  `db:"image_url" json:"image_url"`
+	ImageURL    string  `db:"image_url" json:"image_url" binding:"omitempty,url,max=2048"`
 	OwnerID     string  `db:"owner_id" json:"owner_id"`
-	City        string  `db:"city" json:"city"`
-	Region      string  `db:"region" json:"region"`
+	City        string  `db:"city" json:"city" binding:"max=100"`
+	Region      string  `db:"region" json:"region" binding:"max=100"`
+	Version     int64   `db:"version" json:"version"`
 	CreatedAt   *string `db:"created_at" json:"created_at"`
 	UpdatedAt   *string `db:"updated_at" json:"updated_at"`
+
+	// DeletedAt is set while the device is in the owner's trash
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+
+	// Status is the listing status (see StatusTransitions); it is changed by the
+	// transition endpoints and the moderation queue, never by create/update
+	Status string `db:"status" json:"status"`
 }
@@ -2,44 +2,55 @@ package model
 
 import (
 	"github.com/gin-gonic/gin"
-	"strconv"
 )
 
+// DeviceFilter is the query of GET /api/devices.
+// `binding` tags are the validation rules, max_price >= min_price is checked in internal/validation.
 type DeviceFilter struct {
-	Category  string
-	Available *bool
-	MinPrice  *float64
-	MaxPrice  *float64
-	City      string
-	Region    string
-	Sort      string
-	Page      int
-	Limit     int
+	Category  string   `form:"category" binding:"omitempty,device_category"`
+	Available *bool    `form:"available"`
+	MinPrice  *float64 `form:"min_price" binding:"omitempty,gte=0"`
+	MaxPrice  *float64 `form:"max_price" binding:"omitempty,gte=0"`
+	City      string   `form:"city" binding:"max=100"`
+	Region    string   `form:"region" binding:"max=100"`
+	Sort      string   `form:"sort,default=recent" binding:"oneof=recent price_asc price_desc"`
+	Page      int      `form:"page,default=1" binding:"min=1"`
+	Limit     int      `form:"limit,default=10" binding:"min=1,max=100"`
 }
 
-func ParseDeviceFilter(c *gin.Context) DeviceFilter {
-	f := DeviceFilter{
-		Category: c.Query("category"),
-		City:     c.Query("city"),
-		Region:   c.Query("region"),
-		Sort:     c.DefaultQuery("sort", "recent"),
-		Page:     func() int { v, _ := strconv.Atoi(c.DefaultQuery("page", "1")); return v }(),
-		Limit:    func() int { v, _ := strconv.Atoi(c.DefaultQuery("limit", "10")); return v }(),
-	}
+// OwnerDeviceFilter is the query of GET /api/me/devices: the catalog filter plus
+// the listing status, since owners see their devices in every status
+type OwnerDeviceFilter struct {
+	DeviceFilter
+	Status string `form:"status" binding:"omitempty,oneof=draft pending_review published paused archived"`
+}
+
+// ParseDeviceFilter binds and validates the list query parameters
+func ParseDeviceFilter(c *gin.Context) (DeviceFilter, error) {
+	var f DeviceFilter
+	err := c.ShouldBindQuery(&f)
+	return f, err
+}
+
+// ParseOwnerDeviceFilter binds and validates the query of the owner's device list
+func ParseOwnerDeviceFilter(c *gin.Context) (OwnerDeviceFilter, error) {
+	var f OwnerDeviceFilter
+	err := c.ShouldBindQuery(&f)
+	return f, err
+}
+
+// TrendingQuery is the query of GET /api/devices/trending. Category and city narrow
+// the ranking; with both, the category ranking is filtered by city.
+type TrendingQuery struct {
+	Window   string `form:"window" binding:"omitempty,oneof=24h 7d 30d"` // empty: trending.DefaultWindow
+	Category string `form:"category" binding:"omitempty,device_category"`
+	City     string `form:"city" binding:"max=100"`
+	Limit    int    `form:"limit,default=10" binding:"min=1,max=100"`
+}
 
-	if s := c.Query("available"); s != "" {
-		v := s == "true"
-		f.Available = &v
-	}
-	if s := c.Query("min_price"); s != "" {
-		if v, err := strconv.ParseFloat(s, 64); err == nil {
-			f.MinPrice = &v
-		}
-	}
-	if s := c.Query("max_price"); s != "" {
-		if v, err := strconv.ParseFloat(s, 64); err == nil {
-			f.MaxPrice = &v
-		}
-	}
-	return f
+// ParseTrendingQuery binds and validates the trending query parameters
+func ParseTrendingQuery(c *gin.Context) (TrendingQuery, error) {
+	var q TrendingQuery
+	err := c.ShouldBindQuery(&q)
+	return q, err
 }
@@ -0,0 +1,48 @@
+package model
+
+// DeviceStats are the per-device numbers of the owner dashboard; Views is all-time.
+// Rentals are handled outside this service, so UpcomingRentals and EarningsToDate
+// stay null until a booking source is wired in.
+type DeviceStats struct {
+	Favorites       int64    `json:"favorites"`
+	Views           *int64   `json:"views"`
+	UpcomingRentals *int64   `json:"upcoming_rentals"`
+	EarningsToDate  *float64 `json:"earnings_to_date"`
+}
+
+// OwnerDevice is a device in the owner dashboard
+type OwnerDevice struct {
+	Device
+	Stats DeviceStats `json:"stats"`
+}
+
+// DailyStats are the counters of one device for one UTC day (or a total of days)
+type DailyStats struct {
+	Day              string `db:"day" json:"day,omitempty"`
+	Views            int64  `db:"views" json:"views"`
+	Impressions      int64  `db:"impressions" json:"impressions"`
+	FavoritesAdded   int64  `db:"favorites_added" json:"favorites_added"`
+	FavoritesRemoved int64  `db:"favorites_removed" json:"favorites_removed"`
+
+	// Conversion is favorites added per view; null without views
+	Conversion *float64 `db:"-" json:"conversion"`
+}
+
+// SetConversion fills Conversion from the counters
+func (s *DailyStats) SetConversion() {
+	if s.Views == 0 {
+		s.Conversion = nil
+		return
+	}
+	c := float64(s.FavoritesAdded) / float64(s.Views)
+	s.Conversion = &c
+}
+
+// StatsReport is the response of GET /api/devices/:id/stats
+type StatsReport struct {
+	DeviceID string       `json:"device_id"`
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Totals   DailyStats   `json:"totals"`
+	Days     []DailyStats `json:"days"`
+}
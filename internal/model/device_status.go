@@ -0,0 +1,70 @@
+package model
+
+import (
+	"strings"
+
+	"device-service/internal/domainerr"
+)
+
+// Listing statuses. Only published devices are visible in the public catalog;
+// the owner sees their device in every status.
+const (
+	StatusDraft         = "draft"
+	StatusPendingReview = "pending_review"
+	StatusPublished     = "published"
+	StatusPaused        = "paused"
+	StatusArchived      = "archived"
+)
+
+// StatusTransition is one edge of the listing state machine, triggered by
+// POST /api/devices/:id/<Action>
+type StatusTransition struct {
+	Action string
+	From   []string
+	To     string
+
+	// RequirePublishable checks CheckPublishable before the transition
+	RequirePublishable bool
+}
+
+// StatusTransitions are the owner's moves in the listing state machine:
+// draft → pending_review → published ⇄ paused, anything → archived → draft.
+// pending_review → published (or back to draft / archived) is decided by
+// moderators through the moderation queue.
+var StatusTransitions = []StatusTransition{
+	{Action: "submit", From: []string{StatusDraft}, To: StatusPendingReview, RequirePublishable: true},
+	{Action: "withdraw", From: []string{StatusPendingReview}, To: StatusDraft},
+	{Action: "pause", From: []string{StatusPublished}, To: StatusPaused},
+	{Action: "resume", From: []string{StatusPaused}, To: StatusPublished, RequirePublishable: true},
+	{Action: "archive", From: []string{StatusDraft, StatusPendingReview, StatusPublished, StatusPaused}, To: StatusArchived},
+	{Action: "unarchive", From: []string{StatusArchived}, To: StatusDraft},
+}
+
+// Allows reports whether the transition can start from status
+func (t StatusTransition) Allows(status string) bool {
+	for _, s := range t.From {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPublishable returns a validation error listing the fields that keep the
+// device out of the catalog, or nil if it can be published
+func (d *Device) CheckPublishable() error {
+	var fields []domainerr.FieldError
+	if strings.TrimSpace(d.Name) == "" {
+		fields = append(fields, domainerr.FieldError{Field: "name", Message: "is required to publish"})
+	}
+	if d.PricePerDay <= 0 {
+		fields = append(fields, domainerr.FieldError{Field: "price_per_day", Message: "must be set to publish"})
+	}
+	if strings.TrimSpace(d.ImageURL) == "" {
+		fields = append(fields, domainerr.FieldError{Field: "image_url", Message: "is required to publish"})
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &domainerr.Error{Kind: domainerr.KindValidation, Message: "device is not ready to be published", Fields: fields}
+}
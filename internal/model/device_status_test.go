@@ -0,0 +1,101 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"device-service/internal/domainerr"
+)
+
+func TestStatusTransitionMatrix(t *testing.T) {
+	statuses := []string{StatusDraft, StatusPendingReview, StatusPublished, StatusPaused, StatusArchived}
+	// action → the statuses it starts from and where it leads
+	want := map[string]struct {
+		from string
+		to   string
+	}{
+		"submit":    {"draft", StatusPendingReview},
+		"withdraw":  {"pending_review", StatusDraft},
+		"pause":     {"published", StatusPaused},
+		"resume":    {"paused", StatusPublished},
+		"archive":   {"draft,paused,pending_review,published", StatusArchived},
+		"unarchive": {"archived", StatusDraft},
+	}
+
+	if len(StatusTransitions) != len(want) {
+		t.Errorf("got %d transitions, want %d", len(StatusTransitions), len(want))
+	}
+	for _, tr := range StatusTransitions {
+		w, ok := want[tr.Action]
+		if !ok {
+			t.Errorf("unexpected action %s", tr.Action)
+			continue
+		}
+		var from []string
+		for _, s := range statuses {
+			if tr.Allows(s) {
+				from = append(from, s)
+			}
+		}
+		sort.Strings(from)
+		if got := strings.Join(from, ","); got != w.from {
+			t.Errorf("%s starts from %s, want %s", tr.Action, got, w.from)
+		}
+		if tr.To != w.to {
+			t.Errorf("%s leads to %s, want %s", tr.Action, tr.To, w.to)
+		}
+	}
+}
+
+func TestOwnerCannotPublish(t *testing.T) {
+	// Publishing is the moderators' decision: no owner move leaves review for the catalog
+	for _, tr := range StatusTransitions {
+		if tr.Allows(StatusPendingReview) && tr.To == StatusPublished {
+			t.Errorf("%s publishes a device waiting for review", tr.Action)
+		}
+	}
+}
+
+func TestTransitionsIntoTheCatalogRequirePublishable(t *testing.T) {
+	for _, tr := range StatusTransitions {
+		entersReview := tr.To == StatusPendingReview || tr.To == StatusPublished
+		if entersReview != tr.RequirePublishable {
+			t.Errorf("%s: RequirePublishable = %v", tr.Action, tr.RequirePublishable)
+		}
+	}
+}
+
+func TestCheckPublishable(t *testing.T) {
+	tests := []struct {
+		name   string
+		device Device
+		fields []string
+	}{
+		{"complete", Device{Name: "Drill", PricePerDay: 5, ImageURL: "https://img/1.jpg"}, nil},
+		{"empty", Device{}, []string{"name", "price_per_day", "image_url"}},
+		{"blank name", Device{Name: "  ", PricePerDay: 5, ImageURL: "https://img/1.jpg"}, []string{"name"}},
+		{"no image", Device{Name: "Drill", PricePerDay: 5}, []string{"image_url"}},
+	}
+	for _, tt := range tests {
+		err := tt.device.CheckPublishable()
+		if tt.fields == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		var de *domainerr.Error
+		if !errors.As(err, &de) || de.Kind != domainerr.KindValidation {
+			t.Fatalf("%s: got %v, want a validation error", tt.name, err)
+		}
+		var got []string
+		for _, f := range de.Fields {
+			got = append(got, f.Field)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+			t.Errorf("%s: got fields %v, want %v", tt.name, got, tt.fields)
+		}
+	}
+}
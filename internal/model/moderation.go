@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Moderation item kinds
+const (
+	ModerationNew    = "new"    // submitted for review
+	ModerationEdit   = "edit"   // materially edited while listed
+	ModerationReport = "report" // hidden after user reports
+)
+
+// Moderation item statuses
+const (
+	ModerationPending          = "pending"
+	ModerationApproved         = "approved"
+	ModerationRejected         = "rejected"
+	ModerationChangesRequested = "changes_requested"
+	ModerationWithdrawn        = "withdrawn" // the owner took the device out of review
+)
+
+// ModerationItem is one entry of the moderation queue and, once decided, of the
+// device's moderation history
+type ModerationItem struct {
+	ID            int64           `db:"id" json:"id"`
+	DeviceID      string          `db:"device_id" json:"device_id"`
+	Kind          string          `db:"kind" json:"kind"`
+	DeviceVersion int64           `db:"device_version" json:"device_version"`
+	Status        string          `db:"status" json:"status"`
+	Flags         json.RawMessage `db:"flags" json:"flags"`
+	Flagged       bool            `db:"flagged" json:"flagged"`
+	Reason        *string         `db:"reason" json:"reason,omitempty"`
+	ModeratorID   *string         `db:"moderator_id" json:"moderator_id,omitempty"`
+	DecidedAt     *time.Time      `db:"decided_at" json:"decided_at,omitempty"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// ModerationDecision is the body of POST /api/admin/moderation/:id/{reject,request-changes}
+type ModerationDecision struct {
+	Reason string `json:"reason" binding:"required,notblank,max=1000"`
+}
@@ -0,0 +1,13 @@
+package model
+
+// Where a recommendation came from
+const (
+	RecommendationFavorites = "favorites" // favorited by people who favorited the same devices
+	RecommendationTrending  = "trending"  // popular right now; used when there is too little history
+)
+
+// Recommendation is one device of GET /api/me/recommendations
+type Recommendation struct {
+	Device
+	Source string `json:"source"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// ReportReasons is the closed list of reasons a listing can be reported for
+var ReportReasons = []string{
+	"scam",
+	"prohibited_item",
+	"counterfeit",
+	"stolen",
+	"misleading",
+	"offensive",
+	"other",
+}
+
+// IsReportReason reports whether r is one of ReportReasons
+func IsReportReason(r string) bool {
+	for _, v := range ReportReasons {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Report statuses
+const (
+	ReportOpen      = "open"
+	ReportUpheld    = "upheld"    // the listing was taken down
+	ReportDismissed = "dismissed" // nothing wrong with the listing
+)
+
+// Report is a user's complaint about a listing
+type Report struct {
+	ID             int64      `db:"id" json:"id"`
+	DeviceID       string     `db:"device_id" json:"device_id"`
+	ReporterID     string     `db:"reporter_id" json:"reporter_id"`
+	Reason         string     `db:"reason" json:"reason"`
+	Comment        string     `db:"comment" json:"comment"`
+	Status         string     `db:"status" json:"status"`
+	ResolutionNote *string    `db:"resolution_note" json:"resolution_note,omitempty"`
+	ResolvedBy     *string    `db:"resolved_by" json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `db:"resolved_at" json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+}
+
+// ReportInput is the body of POST /api/devices/:id/report
+type ReportInput struct {
+	Reason  string `json:"reason" binding:"required,report_reason"`
+	Comment string `json:"comment" binding:"max=2000"`
+}
+
+// ReportResolution is the body of POST /api/admin/devices/:id/reports/resolve
+type ReportResolution struct {
+	Resolution string `json:"resolution" binding:"required,oneof=upheld dismissed"`
+	Note       string `json:"note" binding:"max=1000"`
+}
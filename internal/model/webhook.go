@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/lib/pq"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription is an owner's HTTP callback for events about their devices.
+// Secret is only returned when the subscription is created or the secret rotated.
+type WebhookSubscription struct {
+	ID                  string         `db:"id" json:"id"`
+	OwnerID             string         `db:"owner_id" json:"owner_id"`
+	URL                 string         `db:"url" json:"url"`
+	EventTypes          pq.StringArray `db:"event_types" json:"event_types"`
+	Secret              string         `db:"secret" json:"secret,omitempty"`
+	Active              bool           `db:"active" json:"active"`
+	ConsecutiveFailures int            `db:"consecutive_failures" json:"consecutive_failures"`
+	DisabledAt          *time.Time     `db:"disabled_at" json:"disabled_at,omitempty"`
+	DisabledReason      *string        `db:"disabled_reason" json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt           time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// WebhookInput is the body of create/update requests
+type WebhookInput struct {
+	URL        string   `json:"url" binding:"required,url,max=2048"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,dive,event_type"`
+	Active     *bool    `json:"active"`
+}
+
+// WebhookDelivery is one event sent (or being sent) to one subscription
+type WebhookDelivery struct {
+	ID             int64           `db:"id" json:"id"`
+	SubscriptionID string          `db:"subscription_id" json:"subscription_id"`
+	EventID        int64           `db:"event_id" json:"event_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode *int            `db:"last_status_code" json:"last_status_code,omitempty"`
+	LastError      *string         `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+}
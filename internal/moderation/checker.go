@@ -0,0 +1,147 @@
+// Package moderation runs the automatic checks on listings entering the moderation
+// queue. A check never rejects anything by itself: it flags the item so moderators
+// look at it first, and a flagged edit takes the device out of the catalog.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"device-service/config"
+	"device-service/internal/metrics"
+	"device-service/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Flag codes
+const (
+	FlagBannedWord      = "banned_word"
+	FlagMissingImage    = "missing_image"
+	FlagSuspiciousPrice = "suspicious_price"
+)
+
+// Flag is a finding of an automatic check
+type Flag struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Checker runs the automatic checks configured in config.ModerationConfig
+type Checker struct {
+	bannedWords     []string
+	requireImage    bool
+	priceFactor     float64
+	priceMinSamples int
+}
+
+func NewChecker(cfg config.ModerationConfig) *Checker {
+	words := make([]string, 0, len(cfg.BannedWords))
+	for _, w := range cfg.BannedWords {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			words = append(words, w)
+		}
+	}
+	return &Checker{
+		bannedWords:     words,
+		requireImage:    cfg.RequireImage,
+		priceFactor:     cfg.PriceFactor,
+		priceMinSamples: cfg.PriceMinSamples,
+	}
+}
+
+// Check returns the flags raised for d; q is used to look up the category's prices
+func (c *Checker) Check(ctx context.Context, q sqlx.QueryerContext, d *model.Device) ([]Flag, error) {
+	flags := []Flag{}
+
+	if word, ok := c.bannedWord(d.Name + "\n" + d.Description); ok {
+		flags = append(flags, Flag{Code: FlagBannedWord, Message: fmt.Sprintf("contains banned word %q", word)})
+	}
+	if c.requireImage && strings.TrimSpace(d.ImageURL) == "" {
+		flags = append(flags, Flag{Code: FlagMissingImage, Message: "no image"})
+	}
+	if c.priceFactor > 0 {
+		flag, err := c.checkPrice(ctx, q, d)
+		if err != nil {
+			return nil, err
+		}
+		if flag != nil {
+			flags = append(flags, *flag)
+		}
+	}
+
+	for _, f := range flags {
+		metrics.ModerationFlags.WithLabelValues(f.Code).Inc()
+	}
+	return flags, nil
+}
+
+// bannedWord finds the first banned word or phrase in text. Single words match whole
+// words only, so "scam" doesn't hit "scampi"; phrases match as substrings.
+func (c *Checker) bannedWord(text string) (string, bool) {
+	if len(c.bannedWords) == 0 {
+		return "", false
+	}
+	text = strings.ToLower(text)
+	words := map[string]bool{}
+	for _, w := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[w] = true
+	}
+	for _, banned := range c.bannedWords {
+		if strings.ContainsRune(banned, ' ') {
+			if strings.Contains(text, banned) {
+				return banned, true
+			}
+		} else if words[banned] {
+			return banned, true
+		}
+	}
+	return "", false
+}
+
+// checkPrice flags a price more than priceFactor times above or below the median of
+// the published devices of the same category. Thin categories are skipped.
+func (c *Checker) checkPrice(ctx context.Context, q sqlx.QueryerContext, d *model.Device) (*Flag, error) {
+	var stats struct {
+		Count  int     `db:"count"`
+		Median float64 `db:"median"`
+	}
+	err := sqlx.GetContext(ctx, q, &stats, `
+        SELECT COUNT(*) AS count,
+               COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY price_per_day), 0) AS median
+        FROM devices
+        WHERE category = $1 AND status = 'published' AND deleted_at IS NULL AND id <> $2
+    `, d.Category, d.ID)
+	if err != nil {
+		return nil, fmt.Errorf("category price median: %w", err)
+	}
+	return c.priceFlag(d, stats.Count, stats.Median), nil
+}
+
+// priceFlag compares the price of d with the median of count other devices of its category
+func (c *Checker) priceFlag(d *model.Device, count int, median float64) *Flag {
+	if count < c.priceMinSamples || median <= 0 || d.PricePerDay <= 0 {
+		return nil
+	}
+	if d.PricePerDay > median*c.priceFactor || d.PricePerDay < median/c.priceFactor {
+		return &Flag{
+			Code:    FlagSuspiciousPrice,
+			Message: fmt.Sprintf("price %.2f is far from the %s median of %.2f", d.PricePerDay, d.Category, median),
+		}
+	}
+	return nil
+}
+
+// MaterialChange reports whether the edit from before to after touches what
+// moderators review: the text, the category, the image or the price
+func MaterialChange(before, after *model.Device) bool {
+	return before.Name != after.Name ||
+		before.Description != after.Description ||
+		before.Category != after.Category ||
+		before.ImageURL != after.ImageURL ||
+		before.PricePerDay != after.PricePerDay
+}
@@ -0,0 +1,98 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+
+	"device-service/config"
+	"device-service/internal/model"
+)
+
+func TestBannedWord(t *testing.T) {
+	c := NewChecker(config.ModerationConfig{BannedWords: []string{" Scam ", "", "wire transfer"}})
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Cordless drill", ""},
+		{"Not a SCAM, honest", "scam"},
+		{"scampi maker", ""},
+		{"Payment by wire transfer only", "wire transfer"},
+		{"wire, transfer", ""},
+	}
+	for _, tt := range tests {
+		got, ok := c.bannedWord(tt.text)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("bannedWord(%q) = %q, %v; want %q", tt.text, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCheckWithoutPriceCheck(t *testing.T) {
+	// With the price check off the checker never queries, so q may be nil
+	c := NewChecker(config.ModerationConfig{BannedWords: []string{"scam"}, RequireImage: true})
+	flags, err := c.Check(context.Background(), nil, &model.Device{Name: "Scam drill"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flags) != 2 || flags[0].Code != FlagBannedWord || flags[1].Code != FlagMissingImage {
+		t.Errorf("got %+v, want banned_word and missing_image", flags)
+	}
+
+	flags, err = c.Check(context.Background(), nil, &model.Device{Name: "Drill", ImageURL: "https://img/1.jpg"})
+	if err != nil || len(flags) != 0 {
+		t.Errorf("got %+v, %v; want no flags", flags, err)
+	}
+}
+
+func TestPriceFlag(t *testing.T) {
+	c := NewChecker(config.ModerationConfig{PriceFactor: 5, PriceMinSamples: 10})
+	tests := []struct {
+		name   string
+		price  float64
+		count  int
+		median float64
+		flag   bool
+	}{
+		{"near the median", 12, 10, 10, false},
+		{"exactly factor above", 50, 10, 10, false},
+		{"far above", 51, 10, 10, true},
+		{"far below", 1.9, 10, 10, true},
+		{"thin category", 500, 9, 10, false},
+		{"no median", 500, 10, 0, false},
+		{"no price", 0, 10, 10, false},
+	}
+	for _, tt := range tests {
+		f := c.priceFlag(&model.Device{Category: "tools", PricePerDay: tt.price}, tt.count, tt.median)
+		if (f != nil) != tt.flag {
+			t.Errorf("%s: got %+v, want flagged %v", tt.name, f, tt.flag)
+		}
+		if f != nil && f.Code != FlagSuspiciousPrice {
+			t.Errorf("%s: got code %s", tt.name, f.Code)
+		}
+	}
+}
+
+func TestMaterialChange(t *testing.T) {
+	before := model.Device{Name: "Drill", Description: "18V", Category: "tools", ImageURL: "a.jpg", PricePerDay: 5, City: "Kazan"}
+	edits := map[string]func(d *model.Device){
+		"name":        func(d *model.Device) { d.Name = "Hammer drill" },
+		"description": func(d *model.Device) { d.Description = "24V" },
+		"category":    func(d *model.Device) { d.Category = "garden" },
+		"image":       func(d *model.Device) { d.ImageURL = "b.jpg" },
+		"price":       func(d *model.Device) { d.PricePerDay = 6 },
+	}
+	for name, edit := range edits {
+		after := before
+		edit(&after)
+		if !MaterialChange(&before, &after) {
+			t.Errorf("%s edit is not material", name)
+		}
+	}
+
+	after := before
+	after.City = "Moscow"
+	if MaterialChange(&before, &after) {
+		t.Error("city edit is material")
+	}
+}
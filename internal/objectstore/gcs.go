@@ -0,0 +1,73 @@
+package objectstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"device-service/internal/tracing"
+
+	"cloud.google.com/go/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("device-service/internal/objectstore")
+
+// GCS stores objects in a Google Cloud Storage (Firebase Storage) bucket
+type GCS struct {
+	client *storage.Client
+	bucket string
+}
+
+// NewGCS takes ownership of client; Close closes it
+func NewGCS(client *storage.Client, bucket string) *GCS {
+	return &GCS{client: client, bucket: bucket}
+}
+
+func (s *GCS) Put(ctx context.Context, name, contentType string, r io.Reader) (int64, error) {
+	ctx, span := tracer.Start(ctx, "storage.Upload",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("storage.bucket", s.bucket),
+			attribute.String("storage.object", name),
+		))
+	defer span.End()
+
+	wc := s.client.Bucket(s.bucket).Object(name).NewWriter(ctx)
+	wc.ContentType = contentType
+
+	written, err := io.Copy(wc, r)
+	if err != nil {
+		// Not closing the writer leaves no partial object; the caller's ctx cancel aborts it
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "write failed")
+		return written, fmt.Errorf("write %s to Firebase Storage: %w", name, err)
+	}
+
+	// Close finalizes the object; until then nothing is visible in the bucket
+	if err := wc.Close(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "finalize failed")
+		return written, fmt.Errorf("finalize Firebase upload of %s: %w", name, err)
+	}
+
+	span.SetAttributes(attribute.Int64("storage.size", written))
+	return written, nil
+}
+
+// PublicURL: https://storage.googleapis.com/{bucket}/{name}
+func (s *GCS) PublicURL(name string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", s.bucket, name)
+}
+
+// Ping checks the bucket exists and is readable
+func (s *GCS) Ping(ctx context.Context) error {
+	_, err := s.client.Bucket(s.bucket).Attrs(ctx)
+	return err
+}
+
+func (s *GCS) Close() error {
+	return s.client.Close()
+}
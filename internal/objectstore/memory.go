@@ -0,0 +1,46 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Memory keeps objects in process memory, for local runs without cloud credentials and for tests
+type Memory struct {
+	BaseURL string
+
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemory(baseURL string) *Memory {
+	return &Memory{BaseURL: baseURL, objects: map[string][]byte{}}
+}
+
+func (m *Memory) Put(_ context.Context, name, _ string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.objects[name] = data
+	m.mu.Unlock()
+	return int64(len(data)), nil
+}
+
+// Get returns a stored object
+func (m *Memory) Get(name string) ([]byte, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.objects[name]
+	return data, ok
+}
+
+func (m *Memory) PublicURL(name string) string {
+	return m.BaseURL + "/" + name
+}
+
+func (m *Memory) Ping(context.Context) error { return nil }
+
+func (m *Memory) Close() error { return nil }
@@ -0,0 +1,21 @@
+// Package objectstore abstracts where uploaded files are kept.
+package objectstore
+
+import (
+	"context"
+	"io"
+)
+
+// Store writes objects and tells where they are publicly served from
+type Store interface {
+	// Put writes r under name and returns the number of bytes stored
+	Put(ctx context.Context, name, contentType string, r io.Reader) (int64, error)
+
+	// PublicURL is the URL clients download the object from
+	PublicURL(name string) string
+
+	// Ping checks the store is reachable with our credentials
+	Ping(ctx context.Context) error
+
+	Close() error
+}
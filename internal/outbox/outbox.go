@@ -0,0 +1,112 @@
+// Package outbox implements the transactional outbox: repositories write domain
+// events with Enqueue in the same transaction as the change, and the Relay publishes
+// them to a Sink afterwards. Delivery is at-least-once; consumers dedupe by Event.ID.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/jmoiron/sqlx"
+)
+
+// Event types. Every payload carries the owner_id of the device it is about,
+// which is how webhooks are routed to the owner's subscriptions.
+const (
+	DeviceCreated             = "device.created"
+	DeviceUpdated             = "device.updated"
+	DeviceDeleted             = "device.deleted" // moved to the trash
+	DeviceRestored            = "device.restored"
+	DeviceAvailabilityChanged = "device.availability_changed"
+	DeviceStatusChanged       = "device.status_changed"
+	FavoriteAdded             = "favorite.added"
+	FavoriteRemoved           = "favorite.removed"
+)
+
+// EventTypes lists every event type this service emits
+var EventTypes = []string{
+	DeviceCreated,
+	DeviceUpdated,
+	DeviceDeleted,
+	DeviceRestored,
+	DeviceAvailabilityChanged,
+	DeviceStatusChanged,
+	FavoriteAdded,
+	FavoriteRemoved,
+}
+
+// IsEventType reports whether t is one of EventTypes
+func IsEventType(t string) bool {
+	for _, v := range EventTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is one row of the outbox table
+type Event struct {
+	ID          int64           `db:"id" json:"id"`
+	Type        string          `db:"event_type" json:"type"`
+	AggregateID string          `db:"aggregate_id" json:"aggregate_id"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	CreatedAt   time.Time       `db:"created_at" json:"occurred_at"`
+	Attempts    int             `db:"attempts" json:"-"`
+}
+
+// DeviceRef carries the catalog fields of a device so consumers can filter
+// events without loading the device
+type DeviceRef struct {
+	ID       string `db:"id" json:"id"`
+	OwnerID  string `db:"owner_id" json:"owner_id"`
+	Category string `db:"category" json:"category"`
+	City     string `db:"city" json:"city"`
+	Region   string `db:"region" json:"region"`
+	Status   string `db:"status" json:"status"`
+	Version  int64  `db:"version" json:"version"`
+}
+
+// DeletedPayload is the payload of device.deleted
+type DeletedPayload struct {
+	DeviceRef
+}
+
+// AvailabilityPayload is the payload of device.availability_changed
+type AvailabilityPayload struct {
+	DeviceRef
+	Available bool `json:"available"`
+}
+
+// StatusPayload is the payload of device.status_changed; Status is the new status.
+// Reason is set when a moderator or an automatic check made the change.
+type StatusPayload struct {
+	DeviceRef
+	From   string `json:"from"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// FavoritePayload is the payload of favorite.added and favorite.removed
+type FavoritePayload struct {
+	DeviceID string `json:"device_id"`
+	OwnerID  string `json:"owner_id"`
+	UserID   string `json:"user_id"`
+}
+
+// Enqueue stores an event; pass the transaction that makes the change itself,
+// so the event exists if and only if the change is committed.
+func Enqueue(ctx context.Context, tx sqlx.ExecerContext, eventType, aggregateID string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO outbox (event_type, aggregate_id, payload) VALUES ($1, $2, $3)`,
+		eventType, aggregateID, body)
+	if err != nil {
+		return fmt.Errorf("enqueue %s event: %w", eventType, err)
+	}
+	return nil
+}
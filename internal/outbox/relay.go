@@ -0,0 +1,151 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"device-service/internal/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Relay moves events from the outbox table to a Sink. Several replicas can run it
+// at once: rows are claimed with FOR UPDATE SKIP LOCKED.
+type Relay struct {
+	DB   *sqlx.DB
+	Sink Sink
+
+	BatchSize    int
+	PollInterval time.Duration // pause when the outbox is drained
+	MaxBackoff   time.Duration // cap of the exponential retry delay
+	Retention    time.Duration // published rows older than this are deleted
+}
+
+// Run publishes events until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+
+	for {
+		n, err := r.publishBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "outbox relay batch failed", "sink", r.Sink.Name(), "error", err)
+		}
+
+		if time.Since(lastCleanup) > time.Hour {
+			if err := r.cleanup(ctx); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "outbox cleanup failed", "error", err)
+			}
+			lastCleanup = time.Now()
+		}
+
+		// A full batch means there is probably more waiting
+		if n == r.BatchSize && err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishBatch claims up to BatchSize due events, publishes them in id order and
+// records the outcome, all in one transaction. A crash after Publish but before
+// commit makes the event go out again — hence at-least-once.
+// Only the oldest pending event of each device is claimed, so a device's events are
+// delivered in order even while one of them is being retried.
+func (r *Relay) publishBatch(ctx context.Context) (int, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var events []Event
+	err = tx.SelectContext(ctx, &events, `
+        SELECT id, event_type, aggregate_id, payload, created_at, attempts
+        FROM outbox
+        WHERE published_at IS NULL AND next_attempt_at <= NOW()
+          AND NOT EXISTS (
+              SELECT 1 FROM outbox prev
+              WHERE prev.aggregate_id = outbox.aggregate_id
+                AND prev.published_at IS NULL AND prev.id < outbox.id
+          )
+        ORDER BY id
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    `, r.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("claim outbox events: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	var published []int64
+	for _, ev := range events {
+		if err := r.Sink.Publish(ctx, ev); err != nil {
+			metrics.OutboxEvents.WithLabelValues(r.Sink.Name(), "error").Inc()
+			slog.WarnContext(ctx, "outbox event not delivered, will retry",
+				"event_id", ev.ID, "event_type", ev.Type, "attempt", ev.Attempts+1, "error", err)
+
+			delay := r.backoff(ev.Attempts + 1)
+			if _, err := tx.ExecContext(ctx, `
+                UPDATE outbox
+                SET attempts = attempts + 1, last_error = $2,
+                    next_attempt_at = NOW() + CAST($3 AS DOUBLE PRECISION) * INTERVAL '1 second'
+                WHERE id = $1
+            `, ev.ID, err.Error(), delay.Seconds()); err != nil {
+				return 0, fmt.Errorf("schedule outbox retry: %w", err)
+			}
+			continue
+		}
+		metrics.OutboxEvents.WithLabelValues(r.Sink.Name(), "published").Inc()
+		published = append(published, ev.ID)
+	}
+
+	if len(published) > 0 {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE outbox SET published_at = NOW(), attempts = attempts + 1 WHERE id = ANY($1)`,
+			pq.Array(published)); err != nil {
+			return 0, fmt.Errorf("mark outbox events published: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// backoff is 1s, 2s, 4s, ... capped at MaxBackoff
+func (r *Relay) backoff(attempt int) time.Duration {
+	d := time.Duration(math.Pow(2, float64(attempt-1))) * time.Second
+	if d <= 0 || d > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+	return d
+}
+
+func (r *Relay) cleanup(ctx context.Context) error {
+	res, err := r.DB.ExecContext(ctx,
+		`DELETE FROM outbox WHERE published_at < NOW() - CAST($1 AS DOUBLE PRECISION) * INTERVAL '1 second'`,
+		r.Retention.Seconds())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		slog.InfoContext(ctx, "outbox cleaned up", "deleted", n)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+func TestBackoff(t *testing.T) {
+	r := &Relay{MaxBackoff: time.Minute}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := r.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+// testDB opens TEST_DATABASE_URL with the outbox table in a fresh schema,
+// or skips the test when no database is configured
+func testDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sqlx.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("outbox_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	db, err := sqlx.Open("postgres", url+sep+"search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ddl, err := os.ReadFile("../../migrations/002_outbox.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(ddl)); err != nil {
+		t.Fatalf("apply outbox migration: %v", err)
+	}
+	return db
+}
+
+func enqueue(t *testing.T, db *sqlx.DB, events ...[2]string) {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	for _, ev := range events {
+		if err := Enqueue(ctx, tx, ev[0], ev[1], DeviceRef{ID: ev[1]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelayPublishesInDeviceOrder(t *testing.T) {
+	db := testDB(t)
+	enqueue(t, db,
+		[2]string{DeviceCreated, "d1"},
+		[2]string{DeviceUpdated, "d1"},
+		[2]string{DeviceCreated, "d2"},
+	)
+	sink := &Memory{}
+	r := &Relay{DB: db, Sink: sink, BatchSize: 10, MaxBackoff: time.Minute}
+	ctx := context.Background()
+
+	// Only the oldest pending event of each device is claimed per batch
+	if n, err := r.publishBatch(ctx); err != nil || n != 2 {
+		t.Fatalf("first batch: n=%d, err=%v, want 2 events", n, err)
+	}
+	if n, err := r.publishBatch(ctx); err != nil || n != 1 {
+		t.Fatalf("second batch: n=%d, err=%v, want 1 event", n, err)
+	}
+	if n, err := r.publishBatch(ctx); err != nil || n != 0 {
+		t.Fatalf("third batch: n=%d, err=%v, want a drained outbox", n, err)
+	}
+
+	var got []string
+	for _, ev := range sink.Events() {
+		got = append(got, ev.AggregateID+":"+ev.Type)
+	}
+	want := "d1:device.created d2:device.created d1:device.updated"
+	if strings.Join(got, " ") != want {
+		t.Errorf("published %v, want %s", got, want)
+	}
+
+	var pending int
+	if err := db.Get(&pending, `SELECT COUNT(*) FROM outbox WHERE published_at IS NULL`); err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("%d events left unpublished", pending)
+	}
+}
+
+// deviceFailingSink fails every event of one device and remembers the rest
+type deviceFailingSink struct {
+	Memory
+	device string
+}
+
+func (s *deviceFailingSink) Publish(ctx context.Context, ev Event) error {
+	if ev.AggregateID == s.device {
+		return errors.New("receiver is down")
+	}
+	return s.Memory.Publish(ctx, ev)
+}
+
+func TestRelaySchedulesRetry(t *testing.T) {
+	db := testDB(t)
+	enqueue(t, db,
+		[2]string{DeviceCreated, "d1"},
+		[2]string{DeviceUpdated, "d1"},
+		[2]string{DeviceCreated, "d2"},
+	)
+	sink := &deviceFailingSink{device: "d1"}
+	r := &Relay{DB: db, Sink: sink, BatchSize: 10, MaxBackoff: time.Minute}
+	ctx := context.Background()
+
+	if _, err := r.publishBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Events(); len(got) != 1 || got[0].AggregateID != "d2" {
+		t.Fatalf("published %v, want only d2", got)
+	}
+
+	var failed struct {
+		Attempts  int       `db:"attempts"`
+		LastError string    `db:"last_error"`
+		NextAt    time.Time `db:"next_attempt_at"`
+	}
+	err := db.Get(&failed, `
+        SELECT attempts, last_error, next_attempt_at FROM outbox
+        WHERE aggregate_id = 'd1' ORDER BY id LIMIT 1
+    `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Attempts != 1 || failed.LastError != "receiver is down" {
+		t.Errorf("got attempts %d, last error %q", failed.Attempts, failed.LastError)
+	}
+
+	// The retry is not due yet, and the next event of d1 waits behind it
+	if n, err := r.publishBatch(ctx); err != nil || n != 0 {
+		t.Errorf("batch before the retry is due: n=%d, err=%v, want nothing claimed", n, err)
+	}
+}
@@ -0,0 +1,124 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/redis/go-redis/v9"
+)
+
+// Sink delivers events to the outside world. Publish may be called again for an
+// event it has already delivered (at-least-once), so it must tolerate duplicates.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, ev Event) error
+}
+
+// Fanout publishes every event to all sinks. If one of them fails the event is
+// retried for all, so the others see it again.
+type Fanout []Sink
+
+func (f Fanout) Name() string { return "fanout" }
+
+func (f Fanout) Publish(ctx context.Context, ev Event) error {
+	var errs []error
+	for _, s := range f {
+		if err := s.Publish(ctx, ev); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RedisStream appends events to a Redis Stream with XADD. Consumers read it with
+// consumer groups; the stream is trimmed approximately to MaxLen entries.
+type RedisStream struct {
+	Client redis.UniversalClient
+	Stream string
+	MaxLen int64
+}
+
+func (s *RedisStream) Name() string { return "redis" }
+
+func (s *RedisStream) Publish(ctx context.Context, ev Event) error {
+	return s.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.Stream,
+		MaxLen: s.MaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"id":           strconv.FormatInt(ev.ID, 10),
+			"type":         ev.Type,
+			"aggregate_id": ev.AggregateID,
+			"payload":      string(ev.Payload),
+			"occurred_at":  ev.CreatedAt.UTC().Format(time.RFC3339Nano),
+		},
+	}).Err()
+}
+
+// RedisPubSub broadcasts events on a Redis pub/sub channel to every replica,
+// for live streams (see internal/live). Nobody listening means the event is dropped.
+type RedisPubSub struct {
+	Client  redis.UniversalClient
+	Channel string
+}
+
+func (s *RedisPubSub) Name() string { return "pubsub" }
+
+func (s *RedisPubSub) Publish(ctx context.Context, ev Event) error {
+	msg, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return s.Client.Publish(ctx, s.Channel, msg).Err()
+}
+
+// Writer prints every event as a JSON line, e.g. to stdout for local runs
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (s *Writer) Name() string { return "stdout" }
+
+func (s *Writer) Publish(_ context.Context, ev Event) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// Memory collects events in process memory, for tests
+type Memory struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *Memory) Name() string { return "memory" }
+
+func (s *Memory) Publish(_ context.Context, ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, ev)
+	return nil
+}
+
+// Events returns a copy of everything published so far
+func (s *Memory) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
@@ -0,0 +1,71 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+type failingSink struct{ err error }
+
+func (s failingSink) Name() string { return "failing" }
+
+func (s failingSink) Publish(context.Context, Event) error { return s.err }
+
+func TestFanoutPublishesToEverySink(t *testing.T) {
+	first, second := &Memory{}, &Memory{}
+	boom := errors.New("boom")
+	f := Fanout{first, failingSink{err: boom}, second}
+
+	err := f.Publish(context.Background(), Event{ID: 1, Type: DeviceCreated, AggregateID: "d1"})
+	if !errors.Is(err, boom) || !strings.Contains(err.Error(), "failing: boom") {
+		t.Errorf("got %v, want the failing sink's error", err)
+	}
+	// A failing sink doesn't stop the others
+	for i, m := range []*Memory{first, second} {
+		if got := m.Events(); len(got) != 1 || got[0].ID != 1 {
+			t.Errorf("sink %d got %v, want event 1", i, got)
+		}
+	}
+}
+
+func TestMemoryEventsIsACopy(t *testing.T) {
+	m := &Memory{}
+	_ = m.Publish(context.Background(), Event{ID: 1})
+	events := m.Events()
+	events[0].ID = 42
+	if got := m.Events()[0].ID; got != 1 {
+		t.Errorf("stored event changed through the returned slice: id %d", got)
+	}
+}
+
+func TestWriterPrintsJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := int64(1); i <= 2; i++ {
+		ev := Event{ID: i, Type: DeviceUpdated, AggregateID: "d1", Payload: json.RawMessage(`{"owner_id":"u1"}`)}
+		if err := w.Publish(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	var ev struct {
+		ID      int64           `json:"id"`
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.ID != 2 || ev.Type != DeviceUpdated || string(ev.Payload) != `{"owner_id":"u1"}` {
+		t.Errorf("got %+v", ev)
+	}
+}
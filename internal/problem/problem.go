@@ -0,0 +1,71 @@
+// Package problem renders error responses as RFC 7807 problem details (application/problem+json).
+package problem
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of every error response of the service
+const ContentType = "application/problem+json"
+
+// TypeValidation identifies responses that carry per-field errors
+const TypeValidation = "/problems/validation"
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Details is the RFC 7807 problem object
+type Details struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// New builds a generic problem for the status code
+func New(status int, detail string) *Details {
+	return &Details{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Validation builds a 400 problem listing the rejected fields
+func Validation(fields []FieldError) *Details {
+	return &Details{
+		Type:   TypeValidation,
+		Title:  "Validation failed",
+		Status: http.StatusBadRequest,
+		Detail: "One or more fields are invalid",
+		Errors: fields,
+	}
+}
+
+// Render writes p as the response body
+func Render(c *gin.Context, p *Details) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	c.Header("Content-Type", ContentType)
+	c.JSON(p.Status, p)
+}
+
+// Write renders a generic problem for the status code
+func Write(c *gin.Context, status int, detail string) {
+	Render(c, New(status, detail))
+}
+
+// Abort renders a generic problem and stops the handler chain (for middleware)
+func Abort(c *gin.Context, status int, detail string) {
+	c.Abort()
+	Write(c, status, detail)
+}
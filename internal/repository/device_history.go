@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"device-service/internal/audit"
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
+	"device-service/internal/validation"
+
+	"github.com/goccy/go-json"
+)
+
+// GetDeviceOwner returns the owner of a device, including one in the trash
+func (r *DeviceRepository) GetDeviceOwner(ctx context.Context, deviceID string) (string, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetDeviceOwner")
+	defer span.End()
+
+	var ownerID string
+	err := r.DB.GetContext(ctx, &ownerID, `SELECT owner_id FROM devices WHERE id = $1`, deviceID)
+	return ownerID, translateError(err, "device")
+}
+
+// GetHistory returns up to limit audit entries of the device, newest first.
+// beforeID > 0 continues a previous page.
+func (r *DeviceRepository) GetHistory(ctx context.Context, deviceID string, limit int, beforeID int64) ([]audit.Entry, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetHistory")
+	defer span.End()
+
+	entries := []audit.Entry{}
+	err := r.DB.SelectContext(ctx, &entries, `
+        SELECT `+audit.Columns+`
+        FROM device_audit
+        WHERE device_id = $1 AND (CAST($3 AS BIGINT) = 0 OR id < $3)
+        ORDER BY id DESC
+        LIMIT $2
+    `, deviceID, limit, beforeID)
+	return entries, translateError(err, "device")
+}
+
+// RevertDevice restores the owner-editable fields of the device to what they were at
+// toVersion. It is a regular write: the version goes up and the revert is audited.
+// expectedVersion 0 skips the version check.
+func (r *DeviceRepository) RevertDevice(ctx context.Context, deviceID, ownerID string, toVersion, expectedVersion int64) (*model.Device, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.RevertDevice")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockForWrite(ctx, tx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if before.OwnerID != ownerID {
+		return nil, domainerr.Forbidden("device belongs to another user")
+	}
+
+	var snapshot json.RawMessage
+	err = tx.GetContext(ctx, &snapshot, `
+        SELECT after FROM device_audit
+        WHERE device_id = $1 AND version = $2 AND after IS NOT NULL
+        ORDER BY id DESC
+        LIMIT 1
+    `, deviceID, toVersion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domainerr.NotFound("version %d not found in device history", toVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var target model.Device
+	if err := json.Unmarshal(snapshot, &target); err != nil {
+		return nil, err
+	}
+
+	// Only what the owner can edit; identity, ownership and timestamps stay
+	device := *before
+	device.Name = target.Name
+	device.Description = target.Description
+	device.Category = target.Category
+	device.PricePerDay = target.PricePerDay
+	device.Available = target.Available
+	device.ImageURL = target.ImageURL
+	device.City = target.City
+	device.Region = target.Region
+	device.OwnerID = ownerID
+	device.Version = expectedVersion
+
+	// The snapshot may predate the current rules (e.g. the category list of migrations/011)
+	if err := validation.Struct(&device); err != nil {
+		if fields := validation.FieldErrors(err); fields != nil {
+			return nil, domainerr.Unprocessable(fields, "version %d no longer passes validation", toVersion)
+		}
+		return nil, err
+	}
+
+	if err := r.update(ctx, tx, &device, before, audit.ActionRevert); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
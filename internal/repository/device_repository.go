@@ -4,215 +4,528 @@ import (
 	"context"
 	"crypto/sha1"
 	"database/sql"
-	"device-service/config"
+	"device-service/internal/audit"
+	"device-service/internal/domainerr"
+	"device-service/internal/metrics"
 	"device-service/internal/model"
+	"device-service/internal/moderation"
+	"device-service/internal/outbox"
+	"errors"
 	"fmt"
 	"github.com/goccy/go-json"
 	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
 	"time"
 )
 
 type DeviceRepository struct {
-	DB *sqlx.DB
+	DB         *sqlx.DB
+	Cache      redis.UniversalClient // кеш списков устройств
+	Moderation *moderation.Checker   // автоматические проверки при отправке на модерацию
 }
 
-func NewDeviceRepository(db *sqlx.DB) *DeviceRepository {
-	return &DeviceRepository{DB: db}
+func NewDeviceRepository(db *sqlx.DB, cache redis.UniversalClient, checker *moderation.Checker) *DeviceRepository {
+	return &DeviceRepository{DB: db, Cache: cache, Moderation: checker}
 }
 
+// CreateDevice inserts the device and emits device.created
 func (r *DeviceRepository) CreateDevice(ctx context.Context, d *model.Device) error {
+	ctx, span := startSpan(ctx, "DeviceRepository.CreateDevice")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
     INSERT INTO devices ( name, description, category, price_per_day, available, image_url, owner_id, city, region)
     VALUES (:name, :description, :category, :price_per_day, :available, :image_url, :owner_id, :city, :region)
-    RETURNING id, created_at, updated_at
+    RETURNING id, created_at, updated_at, version, status
     `
-	stmt, err := r.DB.PrepareNamedContext(ctx, query)
+	stmt, err := tx.PrepareNamedContext(ctx, query)
 	if err != nil {
 		return err
 	}
-	return stmt.GetContext(ctx, d, d)
+	defer stmt.Close()
+	if err := stmt.GetContext(ctx, d, d); err != nil {
+		return translateError(err, "device")
+	}
+
+	if err := outbox.Enqueue(ctx, tx, outbox.DeviceCreated, d.ID, d); err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, tx, d.ID, d.Version, d.OwnerID, audit.ActionCreate, nil, d); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *DeviceRepository) GetAllDevices(ctx context.Context, f model.DeviceFilter) ([]model.Device, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetAllDevices")
+	defer span.End()
+
 	// 1. Build a stable Redis key from the filter
 	filterJSON, _ := json.Marshal(f)
 	cacheKey := fmt.Sprintf("devices:%x", sha1.Sum(filterJSON))
 
 	// 2. Try cache
-	if cached, err := config.RedisClient.Get(ctx, cacheKey).Result(); err == nil {
+	cached, err := r.Cache.Get(ctx, cacheKey).Result()
+	switch {
+	case err == nil:
 		var devices []model.Device
 		if err := json.Unmarshal([]byte(cached), &devices); err == nil {
+			metrics.CacheRequests.WithLabelValues(metrics.CacheDevicesList, "hit").Inc()
+			slog.DebugContext(ctx, "devices list served from cache", "cache_key", cacheKey)
 			return devices, nil
 		}
+		metrics.CacheRequests.WithLabelValues(metrics.CacheDevicesList, "error").Inc()
+	case errors.Is(err, redis.Nil):
+		metrics.CacheRequests.WithLabelValues(metrics.CacheDevicesList, "miss").Inc()
+	default:
+		metrics.CacheRequests.WithLabelValues(metrics.CacheDevicesList, "error").Inc()
+		slog.WarnContext(ctx, "devices cache read failed", "cache_key", cacheKey, "error", err)
 	}
 
 	// 3. Build dynamic SQL
-	baseQuery := `SELECT * FROM devices WHERE 1=1`
-	args := []interface{}{}
-	idx := 1
+	// Only published devices are listed; drafts, paused and archived ones stay with the owner
+	baseQuery, args := appendDeviceFilter(`SELECT * FROM devices WHERE deleted_at IS NULL AND status = 'published'`, nil, f)
+
+	// 4. Execute the query
+	var devices []model.Device
+	if err := r.DB.SelectContext(ctx, &devices, baseQuery, args...); err != nil {
+		return nil, err
+	}
 
+	slog.DebugContext(ctx, "devices list loaded from db", "count", len(devices))
+
+	// 5. Cache the result for 60s
+	if payload, err := json.Marshal(devices); err == nil {
+		if err := r.Cache.Set(ctx, cacheKey, payload, 60*time.Second).Err(); err != nil {
+			slog.WarnContext(ctx, "devices cache write failed", "cache_key", cacheKey, "error", err)
+		}
+	}
+
+	return devices, nil
+}
+
+// appendDeviceFilter adds the conditions, sort and page of f to query, whose own
+// placeholders are args
+func appendDeviceFilter(query string, args []interface{}, f model.DeviceFilter) (string, []interface{}) {
+	idx := len(args) + 1
 	if f.Category != "" {
-		baseQuery += fmt.Sprintf(" AND category = $%d", idx)
+		query += fmt.Sprintf(" AND category = $%d", idx)
 		args = append(args, f.Category)
 		idx++
 	}
 	if f.Available != nil {
-		baseQuery += fmt.Sprintf(" AND available = $%d", idx)
+		query += fmt.Sprintf(" AND available = $%d", idx)
 		args = append(args, *f.Available)
 		idx++
 	}
 	if f.MinPrice != nil {
-		baseQuery += fmt.Sprintf(" AND price_per_day >= $%d", idx)
+		query += fmt.Sprintf(" AND price_per_day >= $%d", idx)
 		args = append(args, *f.MinPrice)
 		idx++
 	}
 	if f.MaxPrice != nil {
-		baseQuery += fmt.Sprintf(" AND price_per_day <= $%d", idx)
+		query += fmt.Sprintf(" AND price_per_day <= $%d", idx)
 		args = append(args, *f.MaxPrice)
 		idx++
 	}
 	if f.City != "" {
-		baseQuery += fmt.Sprintf(" AND city ILIKE $%d", idx)
+		query += fmt.Sprintf(" AND city ILIKE $%d", idx)
 		args = append(args, f.City)
 		idx++
 	}
 	if f.Region != "" {
-		baseQuery += fmt.Sprintf(" AND region ILIKE $%d", idx)
+		query += fmt.Sprintf(" AND region ILIKE $%d", idx)
 		args = append(args, f.Region)
 		idx++
 	}
 
 	switch f.Sort {
 	case "price_asc":
-		baseQuery += " ORDER BY price_per_day ASC"
+		query += " ORDER BY price_per_day ASC"
 	case "price_desc":
-		baseQuery += " ORDER BY price_per_day DESC"
+		query += " ORDER BY price_per_day DESC"
 	default:
-		baseQuery += " ORDER BY created_at DESC"
+		query += " ORDER BY created_at DESC"
 	}
 
 	offset := (f.Page - 1) * f.Limit
-	baseQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", idx, idx+1)
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", idx, idx+1)
 	args = append(args, f.Limit, offset)
-
-	// 4. Execute the query
-	var devices []model.Device
-	if err := r.DB.SelectContext(ctx, &devices, baseQuery, args...); err != nil {
-		return nil, err
-	}
-
-	// 5. Cache the result for 60s
-	if payload, err := json.Marshal(devices); err == nil {
-		_ = config.RedisClient.Set(ctx, cacheKey, payload, 60*time.Second).Err()
-	}
-
-	return devices, nil
+	return query, args
 }
+
 func (r *DeviceRepository) GetDeviceByID(ctx context.Context, id string) (*model.Device, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetDeviceByID")
+	defer span.End()
+
 	var device model.Device
-	err := r.DB.GetContext(ctx, &device, "SELECT * FROM devices WHERE id = $1", id)
+	err := r.DB.GetContext(ctx, &device, "SELECT * FROM devices WHERE id = $1 AND deleted_at IS NULL", id)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err, "device")
 	}
 	return &device, nil
 }
 
+// UpdateDevice overwrites the device and bumps its version.
+// device.Version is the version the caller expects, 0 skips the check.
+// On success device.Version and timestamps are refreshed from the DB.
+// Emits device.updated, plus device.availability_changed if the flag flipped.
 func (r *DeviceRepository) UpdateDevice(ctx context.Context, device *model.Device) error {
+	ctx, span := startSpan(ctx, "DeviceRepository.UpdateDevice")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockForWrite(ctx, tx, device.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.update(ctx, tx, device, before, audit.ActionUpdate); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// update writes device over before (locked in tx) and records the change as action
+func (r *DeviceRepository) update(ctx context.Context, tx *sqlx.Tx, device *model.Device, before *model.Device, action string) error {
 	query := `
         UPDATE devices 
         SET name = :name, description = :description, category = :category,
             price_per_day = :price_per_day, available = :available, image_url = :image_url,
-            updated_at = NOW()
-        WHERE id = :id AND owner_id = :owner_id
+            city = :city, region = :region,
+            version = version + 1, updated_at = NOW()
+        WHERE id = :id AND owner_id = :owner_id AND deleted_at IS NULL
+          AND (CAST(:version AS BIGINT) = 0 OR version = :version)
+        RETURNING version, created_at, updated_at, status
     `
-	result, err := r.DB.NamedExecContext(ctx, query, device)
+	stmt, err := tx.PrepareNamedContext(ctx, query)
 	if err != nil {
 		return err
 	}
+	defer stmt.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	expected := device.Version
+	if err := stmt.GetContext(ctx, device, device); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return r.writeMiss(ctx, device.ID, device.OwnerID, expected)
+		}
+		return translateError(err, "device")
+	}
+
+	if err := outbox.Enqueue(ctx, tx, outbox.DeviceUpdated, device.ID, device); err != nil {
+		return err
+	}
+	if device.Available != before.Available {
+		if err := enqueueAvailability(ctx, tx, device); err != nil {
+			return err
+		}
+	}
+	if err := audit.Record(ctx, tx, device.ID, device.Version, device.OwnerID, action, before, device); err != nil {
 		return err
 	}
+	return r.moderateEdit(ctx, tx, before, device)
+}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows // not found or not owner
+// moderateEdit queues a material edit of a device that is listed or under review.
+// If the automatic checks flag it, a listed device goes back to pending_review.
+func (r *DeviceRepository) moderateEdit(ctx context.Context, tx *sqlx.Tx, before, device *model.Device) error {
+	switch device.Status {
+	case model.StatusPublished, model.StatusPaused, model.StatusPendingReview:
+	default:
+		return nil // drafts are checked when submitted
+	}
+	if !moderation.MaterialChange(before, device) {
+		return nil
 	}
 
+	flagged, err := enqueueModeration(ctx, tx, r.Moderation, device, model.ModerationEdit)
+	if err != nil || !flagged || device.Status == model.StatusPendingReview {
+		return err
+	}
+	unlisted, err := setStatus(ctx, tx, device, model.StatusPendingReview, device.OwnerID, "flagged by automatic checks")
+	if err != nil {
+		return err
+	}
+	*device = *unlisted
 	return nil
 }
-func (r *DeviceRepository) DeleteDevice(ctx context.Context, deviceID string, ownerID string) error {
-	query := `DELETE FROM devices WHERE id = $1 AND owner_id = $2`
-	result, err := r.DB.ExecContext(ctx, query, deviceID, ownerID)
+
+// DeleteDevice moves the device to the owner's trash and emits device.deleted;
+// expectedVersion 0 skips the version check. PurgeDeleted removes it for good later.
+func (r *DeviceRepository) DeleteDevice(ctx context.Context, deviceID string, ownerID string, expectedVersion int64) error {
+	ctx, span := startSpan(ctx, "DeviceRepository.DeleteDevice")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	before, err := lockForWrite(ctx, tx, deviceID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	query := `
+        UPDATE devices SET deleted_at = NOW(), version = version + 1, updated_at = NOW()
+        WHERE id = $1 AND owner_id = $2 AND deleted_at IS NULL AND (CAST($3 AS BIGINT) = 0 OR version = $3)
+        RETURNING *`
+	var after model.Device
+	err = tx.GetContext(ctx, &after, query, deviceID, ownerID, expectedVersion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.writeMiss(ctx, deviceID, ownerID, expectedVersion)
+	}
+	if err != nil {
+		return translateError(err, "device")
 	}
 
-	return nil
+	payload := outbox.DeletedPayload{DeviceRef: deviceRef(&after)}
+	if err := outbox.Enqueue(ctx, tx, outbox.DeviceDeleted, deviceID, payload); err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, tx, deviceID, after.Version, ownerID, audit.ActionDelete, before, &after); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
-func (r *DeviceRepository) UpdateAvailability(ctx context.Context, deviceID, ownerID string, available bool) error {
-	query := `UPDATE devices SET available = $1, updated_at = NOW() WHERE id = $2 AND owner_id = $3`
-	result, err := r.DB.ExecContext(ctx, query, available, deviceID, ownerID)
+
+// UpdateAvailability sets the availability flag and returns the new device version.
+// expectedVersion 0 skips the version check. Emits device.availability_changed if the flag flipped.
+func (r *DeviceRepository) UpdateAvailability(ctx context.Context, deviceID, ownerID string, available bool, expectedVersion int64) (int64, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.UpdateAvailability")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	before, err := lockForWrite(ctx, tx, deviceID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	query := `
+        UPDATE devices SET available = $1, version = version + 1, updated_at = NOW()
+        WHERE id = $2 AND owner_id = $3 AND deleted_at IS NULL AND (CAST($4 AS BIGINT) = 0 OR version = $4)
+        RETURNING *
+    `
+	var after model.Device
+	err = tx.GetContext(ctx, &after, query, available, deviceID, ownerID, expectedVersion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, r.writeMiss(ctx, deviceID, ownerID, expectedVersion)
+	}
+	if err != nil {
+		return 0, translateError(err, "device")
 	}
 
-	return nil
+	if available != before.Available {
+		if err := enqueueAvailability(ctx, tx, &after); err != nil {
+			return 0, err
+		}
+	}
+	if err := audit.Record(ctx, tx, deviceID, after.Version, ownerID, audit.ActionAvailability, before, &after); err != nil {
+		return 0, err
+	}
+	return after.Version, tx.Commit()
+}
+
+// lockForWrite locks the device row for the rest of tx and returns its current state
+func lockForWrite(ctx context.Context, tx *sqlx.Tx, deviceID string) (*model.Device, error) {
+	var d model.Device
+	err := tx.GetContext(ctx, &d, `SELECT * FROM devices WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, deviceID)
+	if err != nil {
+		return nil, translateError(err, "device")
+	}
+	return &d, nil
+}
+
+// deviceRef picks the catalog fields of d for event payloads
+func deviceRef(d *model.Device) outbox.DeviceRef {
+	return outbox.DeviceRef{
+		ID: d.ID, OwnerID: d.OwnerID, Category: d.Category,
+		City: d.City, Region: d.Region, Status: d.Status, Version: d.Version,
+	}
+}
+
+func enqueueAvailability(ctx context.Context, tx *sqlx.Tx, d *model.Device) error {
+	payload := outbox.AvailabilityPayload{DeviceRef: deviceRef(d), Available: d.Available}
+	return outbox.Enqueue(ctx, tx, outbox.DeviceAvailabilityChanged, d.ID, payload)
+}
+
+// writeMiss explains why a guarded write touched no rows: the device doesn't exist (NotFound),
+// belongs to someone else (Forbidden) or has moved past expectedVersion (PreconditionFailed).
+func (r *DeviceRepository) writeMiss(ctx context.Context, deviceID, ownerID string, expectedVersion int64) error {
+	var current struct {
+		OwnerID string `db:"owner_id"`
+		Version int64  `db:"version"`
+	}
+	err := r.DB.GetContext(ctx, &current,
+		`SELECT owner_id, version FROM devices WHERE id = $1 AND deleted_at IS NULL`, deviceID)
+	if err != nil {
+		return translateError(err, "device")
+	}
+	if current.OwnerID != ownerID {
+		slog.InfoContext(ctx, "write to someone else's device rejected", "device_id", deviceID, "user_id", ownerID)
+		return domainerr.Forbidden("device belongs to another user")
+	}
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		return domainerr.PreconditionFailed("device was modified, current version is %d", current.Version)
+	}
+	// The row changed between the write and this lookup
+	return domainerr.Conflict("device was modified concurrently, retry")
 }
 
 // GetCategories returns all distinct categories
 func (r *DeviceRepository) GetCategories(ctx context.Context) ([]string, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetCategories")
+	defer span.End()
+
 	var cats []string
 	err := r.DB.SelectContext(ctx, &cats,
-		`SELECT DISTINCT category FROM devices WHERE category <> '' ORDER BY category`)
+		`SELECT DISTINCT category FROM devices WHERE category <> '' AND deleted_at IS NULL AND status = 'published' ORDER BY category`)
 	return cats, err
 }
 
 // GetCities returns all distinct non-null cities
 func (r *DeviceRepository) GetCities(ctx context.Context) ([]string, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetCities")
+	defer span.End()
+
 	var cities []string
 	err := r.DB.SelectContext(ctx, &cities,
-		`SELECT DISTINCT city FROM devices WHERE city IS NOT NULL AND city <> '' ORDER BY city`)
+		`SELECT DISTINCT city FROM devices WHERE city IS NOT NULL AND city <> '' AND deleted_at IS NULL AND status = 'published' ORDER BY city`)
 	return cities, err
 }
 
 // GetRegions returns all distinct non-null regions
 func (r *DeviceRepository) GetRegions(ctx context.Context) ([]string, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetRegions")
+	defer span.End()
+
 	var regions []string
 	err := r.DB.SelectContext(ctx, &regions,
-		`SELECT DISTINCT region FROM devices WHERE region IS NOT NULL AND region <> '' ORDER BY region`)
+		`SELECT DISTINCT region FROM devices WHERE region IS NOT NULL AND region <> '' AND deleted_at IS NULL AND status = 'published' ORDER BY region`)
 	return regions, err
 }
 
-// GetTrendingDevices returns the top N devices by # of favorites
-func (r *DeviceRepository) GetTrendingDevices(ctx context.Context, limit int) ([]model.Device, error) {
-	var devices []model.Device
-	query := `
-      SELECT d.*
-      FROM devices d
-      LEFT JOIN favorites f ON f.device_id = d.id
-      GROUP BY d.id
-      ORDER BY COUNT(f.device_id) DESC
-      LIMIT $1
-    `
-	err := r.DB.SelectContext(ctx, &devices, query, limit)
+// GetTrash returns the owner's deleted devices, most recently deleted first
+func (r *DeviceRepository) GetTrash(ctx context.Context, ownerID string) ([]model.Device, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetTrash")
+	defer span.End()
+
+	devices := []model.Device{}
+	err := r.DB.SelectContext(ctx, &devices, `
+        SELECT * FROM devices
+        WHERE owner_id = $1 AND deleted_at IS NOT NULL
+        ORDER BY deleted_at DESC
+    `, ownerID)
 	return devices, err
 }
+
+// RestoreDevice takes the device out of the trash and emits device.restored
+func (r *DeviceRepository) RestoreDevice(ctx context.Context, deviceID, ownerID string) (*model.Device, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.RestoreDevice")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var before model.Device
+	err = tx.GetContext(ctx, &before, `
+        SELECT * FROM devices WHERE id = $1 AND owner_id = $2 AND deleted_at IS NOT NULL FOR UPDATE
+    `, deviceID, ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.restoreMiss(ctx, deviceID, ownerID)
+	}
+	if err != nil {
+		return nil, translateError(err, "device")
+	}
+
+	var device model.Device
+	err = tx.GetContext(ctx, &device, `
+        UPDATE devices SET deleted_at = NULL, version = version + 1, updated_at = NOW()
+        WHERE id = $1
+        RETURNING *
+    `, deviceID)
+	if err != nil {
+		return nil, translateError(err, "device")
+	}
+
+	if err := outbox.Enqueue(ctx, tx, outbox.DeviceRestored, device.ID, &device); err != nil {
+		return nil, err
+	}
+	if err := audit.Record(ctx, tx, device.ID, device.Version, ownerID, audit.ActionRestore, &before, &device); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// restoreMiss explains why RestoreDevice touched no rows
+func (r *DeviceRepository) restoreMiss(ctx context.Context, deviceID, ownerID string) error {
+	var current struct {
+		OwnerID   string     `db:"owner_id"`
+		DeletedAt *time.Time `db:"deleted_at"`
+	}
+	err := r.DB.GetContext(ctx, &current, `SELECT owner_id, deleted_at FROM devices WHERE id = $1`, deviceID)
+	if err != nil {
+		return translateError(err, "device")
+	}
+	return restoreMissReason(ownerID, current.OwnerID, current.DeletedAt)
+}
+
+// restoreMissReason is the error for a restore by ownerID of a device now owned by
+// currentOwner and deleted at deletedAt (nil if it is not in the trash)
+func restoreMissReason(ownerID, currentOwner string, deletedAt *time.Time) error {
+	if currentOwner != ownerID {
+		return domainerr.Forbidden("device belongs to another user")
+	}
+	if deletedAt == nil {
+		return domainerr.Conflict("device is not deleted")
+	}
+	return domainerr.Conflict("device was modified concurrently, retry")
+}
+
+// PurgeDeleted permanently removes up to limit devices that have been in the trash
+// longer than retention, with their favorites. Returns how many were removed.
+func (r *DeviceRepository) PurgeDeleted(ctx context.Context, retention time.Duration, limit int) (int64, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.PurgeDeleted")
+	defer span.End()
+
+	// Foreign keys are checked at the end of the statement, after favorites are gone
+	res, err := r.DB.ExecContext(ctx, `
+        WITH doomed AS (
+            SELECT id FROM devices
+            WHERE deleted_at < NOW() - CAST($1 AS DOUBLE PRECISION) * INTERVAL '1 second'
+            ORDER BY deleted_at
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        ), unfavorited AS (
+            DELETE FROM favorites WHERE device_id IN (SELECT id FROM doomed)
+        )
+        DELETE FROM devices WHERE id IN (SELECT id FROM doomed)
+    `, retention.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
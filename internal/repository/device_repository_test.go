@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"device-service/internal/domainerr"
+)
+
+func TestRestoreMissReason(t *testing.T) {
+	deleted := time.Now()
+	tests := []struct {
+		name      string
+		owner     string
+		deletedAt *time.Time
+		want      error
+	}{
+		{"another user's trash", "stranger", &deleted, domainerr.ErrForbidden},
+		{"another user's listed device", "stranger", nil, domainerr.ErrForbidden},
+		{"not deleted", "owner", nil, domainerr.ErrConflict},
+		{"restored concurrently", "owner", &deleted, domainerr.ErrConflict},
+	}
+	for _, tt := range tests {
+		if err := restoreMissReason("owner", tt.owner, tt.deletedAt); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want kind of %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"device-service/internal/metrics"
+	"device-service/internal/model"
+	"device-service/internal/similar"
+
+	"github.com/goccy/go-json"
+	"github.com/redis/go-redis/v9"
+)
+
+// How long a similar list is cached; device changes invalidate it earlier
+const similarCacheTTL = 10 * time.Minute
+
+// GetSimilarDevices returns published devices of other owners that resemble src,
+// best first. Lists are cached in Redis until any device changes.
+func (r *DeviceRepository) GetSimilarDevices(ctx context.Context, src *model.Device, limit int) ([]model.Device, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetSimilarDevices")
+	defer span.End()
+
+	// The generation is bumped on every device event (see similar.Invalidator);
+	// without it the cache is skipped rather than risk serving stale lists
+	cacheKey := ""
+	gen, err := r.Cache.Get(ctx, similar.GenerationKey).Int64()
+	if err == nil || errors.Is(err, redis.Nil) {
+		cacheKey = fmt.Sprintf("similar:%d:%s:v%d:%d", gen, src.ID, src.Version, limit)
+		cached, err := r.Cache.Get(ctx, cacheKey).Result()
+		switch {
+		case err == nil:
+			var devices []model.Device
+			if err := json.Unmarshal([]byte(cached), &devices); err == nil {
+				metrics.CacheRequests.WithLabelValues(metrics.CacheSimilarDevices, "hit").Inc()
+				return devices, nil
+			}
+			metrics.CacheRequests.WithLabelValues(metrics.CacheSimilarDevices, "error").Inc()
+		case errors.Is(err, redis.Nil):
+			metrics.CacheRequests.WithLabelValues(metrics.CacheSimilarDevices, "miss").Inc()
+		default:
+			metrics.CacheRequests.WithLabelValues(metrics.CacheSimilarDevices, "error").Inc()
+			slog.WarnContext(ctx, "similar devices cache read failed", "cache_key", cacheKey, "error", err)
+		}
+	} else {
+		metrics.CacheRequests.WithLabelValues(metrics.CacheSimilarDevices, "error").Inc()
+		slog.WarnContext(ctx, "similar devices cache generation read failed", "error", err)
+	}
+
+	// Candidates share the category or the city; the closest ones are scored in Go
+	var candidates []model.Device
+	err = r.DB.SelectContext(ctx, &candidates, `
+        SELECT * FROM devices
+        WHERE deleted_at IS NULL AND status = 'published'
+          AND id <> $1 AND owner_id <> $2
+          AND (category = $3 OR ($4 <> '' AND city ILIKE $4))
+        ORDER BY (category = $3) DESC, ($4 <> '' AND city ILIKE $4) DESC,
+                 ABS(price_per_day - $5), created_at DESC
+        LIMIT $6
+    `, src.ID, src.OwnerID, src.Category, src.City, src.PricePerDay, similar.PoolSize)
+	if err != nil {
+		return nil, err
+	}
+	devices := similar.Rank(src, candidates, limit)
+
+	if cacheKey != "" {
+		if payload, err := json.Marshal(devices); err == nil {
+			if err := r.Cache.Set(ctx, cacheKey, payload, similarCacheTTL).Err(); err != nil {
+				slog.WarnContext(ctx, "similar devices cache write failed", "cache_key", cacheKey, "error", err)
+			}
+		}
+	}
+	return devices, nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"device-service/internal/model"
+)
+
+// GetDailyStats returns the counters of the device for every UTC day from..to
+// (inclusive), days without activity as zeros. Views reach it with the analytics
+// flush delay.
+func (r *DeviceRepository) GetDailyStats(ctx context.Context, deviceID string, from, to time.Time) ([]model.DailyStats, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetDailyStats")
+	defer span.End()
+
+	days := []model.DailyStats{}
+	err := r.DB.SelectContext(ctx, &days, `
+        SELECT to_char(g.day, 'YYYY-MM-DD') AS day,
+               COALESCE(s.views, 0) AS views,
+               COALESCE(s.impressions, 0) AS impressions,
+               COALESCE(s.favorites_added, 0) AS favorites_added,
+               COALESCE(s.favorites_removed, 0) AS favorites_removed
+        FROM generate_series(CAST($2 AS DATE), CAST($3 AS DATE), INTERVAL '1 day') AS g(day)
+        LEFT JOIN device_daily_stats s ON s.device_id = $1 AND s.day = g.day
+        ORDER BY g.day
+    `, deviceID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, translateError(err, "device")
+	}
+	for i := range days {
+		days[i].SetConversion()
+	}
+	return days, nil
+}
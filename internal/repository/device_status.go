@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+
+	"device-service/internal/audit"
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
+	"device-service/internal/outbox"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TransitionStatus moves the device along the listing state machine and emits
+// device.status_changed. actorID must own the device; moderators move devices
+// through the moderation queue instead. expectedVersion 0 skips the version check.
+// Submitting puts the device in the moderation queue, leaving review takes it out.
+func (r *DeviceRepository) TransitionStatus(ctx context.Context, deviceID, actorID string, t model.StatusTransition, expectedVersion int64) (*model.Device, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.TransitionStatus")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockForWrite(ctx, tx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTransition(before, actorID, t, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	device, err := setStatus(ctx, tx, before, t.To, actorID, "")
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t.To == model.StatusPendingReview:
+		if _, err := enqueueModeration(ctx, tx, r.Moderation, device, model.ModerationNew); err != nil {
+			return nil, err
+		}
+	case before.Status == model.StatusPendingReview:
+		if err := withdrawModeration(ctx, tx, device.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// checkTransition reports why actorID can't apply t to the device, or nil if it can
+func checkTransition(before *model.Device, actorID string, t model.StatusTransition, expectedVersion int64) error {
+	if before.OwnerID != actorID {
+		return domainerr.Forbidden("device belongs to another user")
+	}
+	if expectedVersion != 0 && before.Version != expectedVersion {
+		return domainerr.PreconditionFailed("device was modified, current version is %d", before.Version)
+	}
+	if !t.Allows(before.Status) {
+		return domainerr.Conflict("cannot %s a device in status %s", t.Action, before.Status)
+	}
+	if t.RequirePublishable {
+		return before.CheckPublishable()
+	}
+	return nil
+}
+
+// setStatus changes the status of before (locked in tx), emits device.status_changed
+// with the optional reason and records it in the audit log
+func setStatus(ctx context.Context, tx *sqlx.Tx, before *model.Device, to, actorID, reason string) (*model.Device, error) {
+	var device model.Device
+	err := tx.GetContext(ctx, &device, `
+        UPDATE devices SET status = $1, version = version + 1, updated_at = NOW()
+        WHERE id = $2
+        RETURNING *
+    `, to, before.ID)
+	if err != nil {
+		return nil, translateError(err, "device")
+	}
+
+	payload := outbox.StatusPayload{DeviceRef: deviceRef(&device), From: before.Status, Reason: reason}
+	if err := outbox.Enqueue(ctx, tx, outbox.DeviceStatusChanged, device.ID, payload); err != nil {
+		return nil, err
+	}
+	if err := audit.Record(ctx, tx, device.ID, device.Version, actorID, audit.ActionStatus, before, &device); err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
+)
+
+func transition(action string) model.StatusTransition {
+	for _, t := range model.StatusTransitions {
+		if t.Action == action {
+			return t
+		}
+	}
+	panic("unknown action " + action)
+}
+
+func TestCheckTransition(t *testing.T) {
+	ready := func(status string) *model.Device {
+		return &model.Device{OwnerID: "owner", Version: 3, Status: status,
+			Name: "Drill", PricePerDay: 5, ImageURL: "https://img/1.jpg"}
+	}
+	incomplete := ready(model.StatusDraft)
+	incomplete.ImageURL = ""
+
+	tests := []struct {
+		name     string
+		device   *model.Device
+		actor    string
+		action   string
+		expected int64
+		want     error
+	}{
+		{"submit", ready(model.StatusDraft), "owner", "submit", 0, nil},
+		{"matching version", ready(model.StatusPublished), "owner", "pause", 3, nil},
+		{"another user", ready(model.StatusDraft), "stranger", "submit", 0, domainerr.ErrForbidden},
+		{"stale version", ready(model.StatusPublished), "owner", "pause", 2, domainerr.ErrPreconditionFailed},
+		{"wrong status", ready(model.StatusDraft), "owner", "pause", 0, domainerr.ErrConflict},
+		{"resume archived", ready(model.StatusArchived), "owner", "resume", 0, domainerr.ErrConflict},
+		{"incomplete submit", incomplete, "owner", "submit", 0, domainerr.ErrValidation},
+		{"incomplete archive", incomplete, "owner", "archive", 0, nil},
+		// Ownership is checked before anything that would reveal the device state
+		{"stranger with stale version", ready(model.StatusArchived), "stranger", "pause", 1, domainerr.ErrForbidden},
+	}
+	for _, tt := range tests {
+		err := checkTransition(tt.device, tt.actor, transition(tt.action), tt.expected)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want kind of %v", tt.name, err, tt.want)
+		}
+	}
+}
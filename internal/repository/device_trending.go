@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"device-service/internal/model"
+	"device-service/internal/trending"
+
+	"github.com/lib/pq"
+)
+
+// trendingHeadroom: the ranking is read this many times deeper than the page, so
+// devices unpublished since the last refresh are replaced by the next ranked ones
+const trendingHeadroom = 2
+
+// GetTrendingDevices returns the best devices of the precomputed trending ranking.
+// When the ranking can't fill the page (none computed yet, Redis fails, a quiet scope
+// such as a new category or a window without activity) the rest comes from all-time
+// favorites, so the endpoint always returns devices while the catalog has them.
+func (r *DeviceRepository) GetTrendingDevices(ctx context.Context, q model.TrendingQuery) ([]model.Device, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetTrendingDevices")
+	defer span.End()
+
+	window, scope, n := trendingRanking(q)
+	ids, ok, err := trending.Top(ctx, r.Cache, window, scope, n)
+	if err != nil {
+		slog.WarnContext(ctx, "read trending ranking", "error", err)
+	}
+
+	devices := []model.Device{}
+	if err == nil && ok && len(ids) > 0 {
+		var found []model.Device
+		err = r.DB.SelectContext(ctx, &found, `
+            SELECT * FROM devices
+            WHERE id = ANY($1) AND deleted_at IS NULL AND status = 'published'
+        `, pq.Array(ids))
+		if err != nil {
+			return nil, err
+		}
+		devices = rankedDevices(ids, found, q.City, q.Limit)
+	}
+
+	return topUp(devices, q.Limit, func(exclude []string, n int) ([]model.Device, error) {
+		return r.getMostFavorited(ctx, q, exclude, n)
+	})
+}
+
+// trendingRanking picks the ranking a query reads and how deep (0 means all of it).
+// With both filters the category ranking is read whole and filtered by city.
+func trendingRanking(q model.TrendingQuery) (window, scope string, n int) {
+	window = q.Window
+	if window == "" {
+		window = trending.DefaultWindow
+	}
+	n = q.Limit * trendingHeadroom
+	if q.Category != "" && q.City != "" {
+		n = 0
+	}
+	return window, trending.Scope(q.Category, q.City), n
+}
+
+// rankedDevices puts the loaded devices in ranking order, up to limit. Devices that
+// were not loaded (unpublished since the last refresh) or are in another city drop out.
+func rankedDevices(ids []string, found []model.Device, city string, limit int) []model.Device {
+	byID := make(map[string]*model.Device, len(found))
+	for i := range found {
+		byID[found[i].ID] = &found[i]
+	}
+	devices := []model.Device{}
+	for _, id := range ids {
+		d, ok := byID[id]
+		if !ok || (city != "" && !strings.EqualFold(d.City, city)) {
+			continue
+		}
+		devices = append(devices, *d)
+		if len(devices) == limit {
+			break
+		}
+	}
+	return devices
+}
+
+// topUp fills devices up to limit with what more returns for the missing places;
+// more is told which devices are already on the page
+func topUp(devices []model.Device, limit int, more func(exclude []string, n int) ([]model.Device, error)) ([]model.Device, error) {
+	if len(devices) >= limit {
+		return devices, nil
+	}
+	exclude := make([]string, len(devices))
+	for i, d := range devices {
+		exclude[i] = d.ID
+	}
+	extra, err := more(exclude, limit-len(devices))
+	if err != nil {
+		return nil, err
+	}
+	return append(devices, extra...), nil
+}
+
+// getMostFavorited ranks devices by all-time favorites, computed on the fly, and
+// returns up to limit of them other than exclude
+func (r *DeviceRepository) getMostFavorited(ctx context.Context, q model.TrendingQuery, exclude []string, limit int) ([]model.Device, error) {
+	args := []any{pq.Array(exclude)}
+	query := `
+      SELECT d.*
+      FROM devices d
+      LEFT JOIN favorites f ON f.device_id = d.id
+      WHERE d.deleted_at IS NULL AND d.status = 'published' AND d.id <> ALL($1)`
+	if q.Category != "" {
+		args = append(args, q.Category)
+		query += fmt.Sprintf(" AND d.category = $%d", len(args))
+	}
+	if q.City != "" {
+		args = append(args, q.City)
+		query += fmt.Sprintf(" AND d.city ILIKE $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(`
+      GROUP BY d.id
+      ORDER BY COUNT(f.device_id) DESC
+      LIMIT $%d`, len(args))
+
+	devices := []model.Device{}
+	err := r.DB.SelectContext(ctx, &devices, query, args...)
+	return devices, err
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"device-service/internal/model"
+	"device-service/internal/trending"
+)
+
+func TestTrendingRanking(t *testing.T) {
+	tests := []struct {
+		name   string
+		q      model.TrendingQuery
+		window string
+		scope  string
+		n      int
+	}{
+		{"defaults", model.TrendingQuery{Limit: 10}, trending.DefaultWindow, "all", 20},
+		{"window", model.TrendingQuery{Window: "24h", Limit: 5}, "24h", "all", 10},
+		{"category", model.TrendingQuery{Window: "30d", Category: "cameras", Limit: 10}, "30d", "category:cameras", 20},
+		{"city", model.TrendingQuery{City: "Kazan", Limit: 10}, trending.DefaultWindow, "city:kazan", 20},
+		{"category and city", model.TrendingQuery{Category: "cameras", City: "Kazan", Limit: 10}, trending.DefaultWindow, "category:cameras", 0},
+	}
+	for _, tt := range tests {
+		window, scope, n := trendingRanking(tt.q)
+		if window != tt.window || scope != tt.scope || n != tt.n {
+			t.Errorf("%s: got %s %s %d, want %s %s %d", tt.name, window, scope, n, tt.window, tt.scope, tt.n)
+		}
+	}
+}
+
+func deviceIDs(devices []model.Device) string {
+	ids := make([]string, len(devices))
+	for i, d := range devices {
+		ids[i] = d.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestRankedDevices(t *testing.T) {
+	found := []model.Device{ // as loaded, in no particular order; "b" was unpublished
+		{ID: "d", City: "Kazan"},
+		{ID: "a", City: "Moscow"},
+		{ID: "c", City: "kazan"},
+	}
+	ids := []string{"a", "b", "c", "d"}
+
+	if got := deviceIDs(rankedDevices(ids, found, "", 10)); got != "a,c,d" {
+		t.Errorf("got %s, want ranking order without the unpublished device", got)
+	}
+	if got := deviceIDs(rankedDevices(ids, found, "", 2)); got != "a,c" {
+		t.Errorf("limit 2: got %s", got)
+	}
+	if got := deviceIDs(rankedDevices(ids, found, "KAZAN", 10)); got != "c,d" {
+		t.Errorf("city: got %s", got)
+	}
+}
+
+func TestTopUp(t *testing.T) {
+	favorites := []model.Device{{ID: "f1"}, {ID: "f2"}, {ID: "f3"}}
+	more := func(calls *int, gotExclude *[]string) func([]string, int) ([]model.Device, error) {
+		return func(exclude []string, n int) ([]model.Device, error) {
+			*calls++
+			*gotExclude = exclude
+			return favorites[:n], nil
+		}
+	}
+
+	tests := []struct {
+		name    string
+		ranked  []model.Device
+		limit   int
+		want    string
+		calls   int
+		exclude []string
+	}{
+		{"full page", []model.Device{{ID: "a"}, {ID: "b"}}, 2, "a,b", 0, nil},
+		{"short page", []model.Device{{ID: "a"}}, 3, "a,f1,f2", 1, []string{"a"}},
+		{"empty ranking", []model.Device{}, 2, "f1,f2", 1, []string{}},
+	}
+	for _, tt := range tests {
+		var calls int
+		var exclude []string
+		got, err := topUp(tt.ranked, tt.limit, more(&calls, &exclude))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if deviceIDs(got) != tt.want || calls != tt.calls || !reflect.DeepEqual(exclude, tt.exclude) {
+			t.Errorf("%s: got %s after %d calls excluding %v, want %s after %d excluding %v",
+				tt.name, deviceIDs(got), calls, exclude, tt.want, tt.calls, tt.exclude)
+		}
+	}
+
+	boom := errors.New("boom")
+	if _, err := topUp(nil, 1, func([]string, int) ([]model.Device, error) { return nil, boom }); !errors.Is(err, boom) {
+		t.Errorf("got %v, want the fallback error", err)
+	}
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"device-service/internal/domainerr"
+
+	"github.com/lib/pq"
+)
+
+// translateError maps driver errors caused by the request itself to domain errors.
+// what names the entity for the message, e.g. "device".
+func translateError(err error, what string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return domainerr.NotFound("%s not found", what)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "22P02": // invalid_text_representation, e.g. a malformed UUID in the path
+			return domainerr.NotFound("%s not found", what)
+		case "23503": // foreign_key_violation: the referenced row doesn't exist
+			return domainerr.NotFound("%s not found", what)
+		case "23505": // unique_violation
+			return domainerr.Conflict("%s already exists", what)
+		case "23514": // check_violation: a value the schema rejects slipped past validation
+			return domainerr.Unprocessable(nil, "%s violates constraint %s", what, pqErr.Constraint)
+		}
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"device-service/internal/domainerr"
+
+	"github.com/lib/pq"
+)
+
+func TestTranslateError(t *testing.T) {
+	other := errors.New("connection reset")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"nil", nil, nil},
+		{"no rows", sql.ErrNoRows, domainerr.ErrNotFound},
+		{"wrapped no rows", fmt.Errorf("get: %w", sql.ErrNoRows), domainerr.ErrNotFound},
+		{"bad uuid", &pq.Error{Code: "22P02"}, domainerr.ErrNotFound},
+		{"foreign key", &pq.Error{Code: "23503"}, domainerr.ErrNotFound},
+		{"unique", &pq.Error{Code: "23505"}, domainerr.ErrConflict},
+		{"check", &pq.Error{Code: "23514", Constraint: "devices_category_check"}, domainerr.ErrUnprocessable},
+		{"other driver error", &pq.Error{Code: "40001"}, nil},
+		{"other error", other, other},
+	}
+	for _, tt := range tests {
+		got := translateError(tt.err, "device")
+		switch {
+		case tt.err == nil:
+			if got != nil {
+				t.Errorf("%s: got %v, want nil", tt.name, got)
+			}
+		case tt.want == nil:
+			if domainerr.KindOf(got) != 0 {
+				t.Errorf("%s: got domain error %v, want the error unchanged", tt.name, got)
+			}
+		case !errors.Is(got, tt.want):
+			t.Errorf("%s: got %v, want kind of %v", tt.name, got, tt.want)
+		}
+	}
+}
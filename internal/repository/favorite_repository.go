@@ -2,9 +2,13 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"device-service/internal/domainerr"
 	"device-service/internal/model"
+	"device-service/internal/outbox"
 	"errors"
 	"github.com/jmoiron/sqlx"
+	"log/slog"
 )
 
 type FavoriteRepository struct {
@@ -15,39 +19,110 @@ func NewFavoriteRepository(db *sqlx.DB) *FavoriteRepository {
 	return &FavoriteRepository{DB: db}
 }
 
-// Add a device to the user's favorites
+// Add a device to the user's favorites; emits favorite.added unless it was already there
 func (r *FavoriteRepository) AddFavorite(ctx context.Context, userID, deviceID string) error {
-	_, err := r.DB.ExecContext(ctx,
+	ctx, span := startSpan(ctx, "FavoriteRepository.AddFavorite")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only listed devices can be favorited: not in the trash and published
+	var ownerID string
+	err = tx.GetContext(ctx, &ownerID,
+		`SELECT owner_id FROM devices WHERE id = $1 AND deleted_at IS NULL AND status = 'published'`, deviceID)
+	if err != nil {
+		return translateError(err, "device")
+	}
+
+	res, err := tx.ExecContext(ctx,
 		`INSERT INTO favorites (user_id, device_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
 		userID, deviceID,
 	)
-	return err
+	if err != nil {
+		return translateError(err, "device")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil // already a favorite
+	}
+
+	payload := outbox.FavoritePayload{DeviceID: deviceID, OwnerID: ownerID, UserID: userID}
+	if err := outbox.Enqueue(ctx, tx, outbox.FavoriteAdded, deviceID, payload); err != nil {
+		return err
+	}
+	if err := countFavorite(ctx, tx, deviceID, 1, 0); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Remove a device from the user's favorites
 func (r *FavoriteRepository) RemoveFavorite(ctx context.Context, userID, deviceID string) error {
-	result, err := r.DB.ExecContext(ctx,
-		`DELETE FROM favorites WHERE user_id = $1 AND device_id = $2`,
-		userID, deviceID,
-	)
+	ctx, span := startSpan(ctx, "FavoriteRepository.RemoveFavorite")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	if rows, _ := result.RowsAffected(); rows == 0 {
-		return errors.New("not found")
+	defer tx.Rollback()
+
+	var ownerID string
+	err = tx.GetContext(ctx, &ownerID, `
+        WITH removed AS (
+            DELETE FROM favorites WHERE user_id = $1 AND device_id = $2
+            RETURNING device_id
+        )
+        SELECT d.owner_id FROM removed JOIN devices d ON d.id = removed.device_id
+    `, userID, deviceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domainerr.NotFound("favorite not found")
+	}
+	if err != nil {
+		return translateError(err, "favorite")
+	}
+
+	payload := outbox.FavoritePayload{DeviceID: deviceID, OwnerID: ownerID, UserID: userID}
+	if err := outbox.Enqueue(ctx, tx, outbox.FavoriteRemoved, deviceID, payload); err != nil {
+		return err
 	}
+	if err := countFavorite(ctx, tx, deviceID, 0, 1); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.DebugContext(ctx, "favorite removed", "user_id", userID, "device_id", deviceID)
 	return nil
 }
 
 // List a user's favorites (returns full device info)
 func (r *FavoriteRepository) GetFavorites(ctx context.Context, userID string) ([]model.Device, error) {
+	ctx, span := startSpan(ctx, "FavoriteRepository.GetFavorites")
+	defer span.End()
+
 	var devices []model.Device
 	query := `
       SELECT d.*
       FROM devices d
       JOIN favorites f ON f.device_id = d.id
-      WHERE f.user_id = $1
+      WHERE f.user_id = $1 AND d.deleted_at IS NULL AND d.status = 'published'
     `
 	err := r.DB.SelectContext(ctx, &devices, query, userID)
 	return devices, err
 }
+
+// countFavorite adds to today's favorite counters of the device (device_daily_stats)
+func countFavorite(ctx context.Context, tx *sqlx.Tx, deviceID string, added, removed int) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO device_daily_stats (device_id, day, favorites_added, favorites_removed)
+        VALUES ($1, CAST(NOW() AT TIME ZONE 'UTC' AS DATE), $2, $3)
+        ON CONFLICT (device_id, day) DO UPDATE
+        SET favorites_added = device_daily_stats.favorites_added + EXCLUDED.favorites_added,
+            favorites_removed = device_daily_stats.favorites_removed + EXCLUDED.favorites_removed
+    `, deviceID, added, removed)
+	return err
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
+	"device-service/internal/moderation"
+
+	"github.com/goccy/go-json"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type ModerationRepository struct {
+	DB *sqlx.DB
+}
+
+func NewModerationRepository(db *sqlx.DB) *ModerationRepository {
+	return &ModerationRepository{DB: db}
+}
+
+// ModerationQueueEntry is an item of the queue with the device it is about
+type ModerationQueueEntry struct {
+	model.ModerationItem
+	Device *model.Device `json:"device"`
+}
+
+// ListQueue returns up to limit items in status, flagged first and then oldest first.
+// Items of devices in the trash are left out.
+func (r *ModerationRepository) ListQueue(ctx context.Context, status string, flaggedOnly bool, limit int) ([]ModerationQueueEntry, error) {
+	ctx, span := startSpan(ctx, "ModerationRepository.ListQueue")
+	defer span.End()
+
+	var items []model.ModerationItem
+	err := r.DB.SelectContext(ctx, &items, `
+        SELECT m.* FROM moderation_items m
+        JOIN devices d ON d.id = m.device_id
+        WHERE m.status = $1 AND d.deleted_at IS NULL AND (NOT $2 OR m.flagged)
+        ORDER BY m.flagged DESC, m.created_at, m.id
+        LIMIT $3
+    `, status, flaggedOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.DeviceID
+	}
+	byID, err := devicesByID(ctx, r.DB, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ModerationQueueEntry, 0, len(items))
+	for _, item := range items {
+		entries = append(entries, ModerationQueueEntry{ModerationItem: item, Device: byID[item.DeviceID]})
+	}
+	return entries, nil
+}
+
+// GetDeviceModeration returns the moderation history of the device, newest first
+func (r *ModerationRepository) GetDeviceModeration(ctx context.Context, deviceID string) ([]model.ModerationItem, error) {
+	ctx, span := startSpan(ctx, "ModerationRepository.GetDeviceModeration")
+	defer span.End()
+
+	items := []model.ModerationItem{}
+	err := r.DB.SelectContext(ctx, &items,
+		`SELECT * FROM moderation_items WHERE device_id = $1 ORDER BY id DESC`, deviceID)
+	return items, translateError(err, "device")
+}
+
+// Decide records the moderator's decision on a pending item and moves the device:
+// approved → published (if it was waiting for review), rejected → archived,
+// changes_requested → draft. The owner learns the outcome from device.status_changed.
+func (r *ModerationRepository) Decide(ctx context.Context, itemID int64, moderatorID, decision, reason string) (*model.ModerationItem, error) {
+	ctx, span := startSpan(ctx, "ModerationRepository.Decide")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var item model.ModerationItem
+	err = tx.GetContext(ctx, &item, `SELECT * FROM moderation_items WHERE id = $1 FOR UPDATE`, itemID)
+	if err != nil {
+		return nil, translateError(err, "moderation item")
+	}
+	if item.Status != model.ModerationPending {
+		return nil, domainerr.Conflict("moderation item is already %s", item.Status)
+	}
+
+	device, err := lockForWrite(ctx, tx, item.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := decideItem(ctx, tx, &item, device, decision, moderatorID, reason); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// decideItem closes the pending item (locked in tx together with its device) with the
+// decision and moves the device accordingly. Deciding an item opened by user reports
+// also resolves those reports.
+func decideItem(ctx context.Context, tx *sqlx.Tx, item *model.ModerationItem, device *model.Device, decision, moderatorID, reason string) error {
+	to, err := decisionStatus(device, decision)
+	if err != nil {
+		return err
+	}
+	if to != device.Status {
+		if _, err := setStatus(ctx, tx, device, to, moderatorID, reason); err != nil {
+			return err
+		}
+	}
+
+	err = tx.GetContext(ctx, item, `
+        UPDATE moderation_items
+        SET status = $1, reason = NULLIF($2, ''), moderator_id = $3, decided_at = NOW(), updated_at = NOW()
+        WHERE id = $4
+        RETURNING *
+    `, decision, reason, moderatorID, item.ID)
+	if err != nil {
+		return err
+	}
+
+	if item.Kind == model.ModerationReport {
+		if _, err := resolveReports(ctx, tx, item.DeviceID, reportResolution(decision), moderatorID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decisionStatus is the status the device moves to when its item is decided
+func decisionStatus(device *model.Device, decision string) (string, error) {
+	switch decision {
+	case model.ModerationApproved:
+		if device.Status != model.StatusPendingReview {
+			return device.Status, nil
+		}
+		if err := device.CheckPublishable(); err != nil {
+			return "", err
+		}
+		return model.StatusPublished, nil
+	case model.ModerationRejected:
+		return model.StatusArchived, nil
+	case model.ModerationChangesRequested:
+		return model.StatusDraft, nil
+	default:
+		return "", domainerr.Validation("decision", "is unknown")
+	}
+}
+
+// reportResolution is how deciding a report item resolves the reports behind it:
+// approving the device dismisses them, any other decision upholds them
+func reportResolution(decision string) string {
+	if decision == model.ModerationApproved {
+		return model.ReportDismissed
+	}
+	return model.ReportUpheld
+}
+
+// devicesByID loads the devices with the given IDs, trashed ones included
+func devicesByID(ctx context.Context, db sqlx.QueryerContext, ids []string) (map[string]*model.Device, error) {
+	var devices []model.Device
+	if err := sqlx.SelectContext(ctx, db, &devices, `SELECT * FROM devices WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.Device, len(devices))
+	for i := range devices {
+		byID[devices[i].ID] = &devices[i]
+	}
+	return byID, nil
+}
+
+// enqueueModeration runs the automatic checks on d and puts it in the queue. A device
+// already waiting gets its open item refreshed instead of a second one.
+// Returns whether the checks flagged it.
+func enqueueModeration(ctx context.Context, tx *sqlx.Tx, checker *moderation.Checker, d *model.Device, kind string) (bool, error) {
+	flags, err := checker.Check(ctx, tx, d)
+	if err != nil {
+		return false, err
+	}
+	flagsJSON, err := json.Marshal(flags)
+	if err != nil {
+		return false, err
+	}
+
+	// A new submission stays "new" even if it is edited while waiting, but reports take
+	// over any open item: deciding it must also resolve them (see decideItem)
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO moderation_items (device_id, kind, device_version, flags, flagged)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (device_id) WHERE status = 'pending' DO UPDATE
+        SET kind = CASE WHEN EXCLUDED.kind = 'report' THEN 'report' ELSE moderation_items.kind END,
+            device_version = EXCLUDED.device_version, flags = EXCLUDED.flags,
+            flagged = EXCLUDED.flagged, updated_at = NOW()
+    `, d.ID, kind, d.Version, flagsJSON, len(flags) > 0)
+	if err != nil {
+		return false, err
+	}
+	return len(flags) > 0, nil
+}
+
+// withdrawModeration closes the open item of a device the owner took out of review
+func withdrawModeration(ctx context.Context, tx *sqlx.Tx, deviceID string) error {
+	_, err := tx.ExecContext(ctx, `
+        UPDATE moderation_items SET status = 'withdrawn', updated_at = NOW()
+        WHERE device_id = $1 AND status = 'pending'
+    `, deviceID)
+	return err
+}
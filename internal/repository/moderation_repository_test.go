@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
+)
+
+func TestDecisionStatus(t *testing.T) {
+	device := func(status string) *model.Device {
+		return &model.Device{Status: status, Name: "Drill", PricePerDay: 5, ImageURL: "https://img/1.jpg"}
+	}
+	incomplete := device(model.StatusPendingReview)
+	incomplete.Name = ""
+
+	tests := []struct {
+		name     string
+		device   *model.Device
+		decision string
+		want     string
+		err      error
+	}{
+		{"approve submission or reported", device(model.StatusPendingReview), model.ModerationApproved, model.StatusPublished, nil},
+		{"approve listed edit", device(model.StatusPublished), model.ModerationApproved, model.StatusPublished, nil},
+		{"approve while paused", device(model.StatusPaused), model.ModerationApproved, model.StatusPaused, nil},
+		{"approve incomplete", incomplete, model.ModerationApproved, "", domainerr.ErrValidation},
+		{"reject", device(model.StatusPendingReview), model.ModerationRejected, model.StatusArchived, nil},
+		{"reject listed", device(model.StatusPublished), model.ModerationRejected, model.StatusArchived, nil},
+		{"request changes", device(model.StatusPendingReview), model.ModerationChangesRequested, model.StatusDraft, nil},
+		{"unknown", device(model.StatusPendingReview), model.ModerationWithdrawn, "", domainerr.ErrValidation},
+	}
+	for _, tt := range tests {
+		got, err := decisionStatus(tt.device, tt.decision)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: got %v, want kind of %v", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestReportResolution(t *testing.T) {
+	tests := map[string]string{
+		model.ModerationApproved:         model.ReportDismissed,
+		model.ModerationRejected:         model.ReportUpheld,
+		model.ModerationChangesRequested: model.ReportUpheld,
+	}
+	for decision, want := range tests {
+		if got := reportResolution(decision); got != want {
+			t.Errorf("reportResolution(%s) = %s, want %s", decision, got, want)
+		}
+	}
+}
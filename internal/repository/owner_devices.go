@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+
+	"device-service/internal/model"
+
+	"github.com/lib/pq"
+)
+
+// GetOwnerDevices returns the owner's devices in every status (except the trash),
+// filtered and paged like the catalog, with dashboard stats
+func (r *DeviceRepository) GetOwnerDevices(ctx context.Context, ownerID string, f model.OwnerDeviceFilter) ([]model.OwnerDevice, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetOwnerDevices")
+	defer span.End()
+
+	query, args := appendDeviceFilter(`
+        SELECT * FROM devices
+        WHERE owner_id = $1 AND deleted_at IS NULL AND (CAST($2 AS TEXT) = '' OR status = $2)`,
+		[]interface{}{ownerID, f.Status}, f.DeviceFilter)
+
+	var devices []model.Device
+	if err := r.DB.SelectContext(ctx, &devices, query, args...); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(devices))
+	for i, d := range devices {
+		ids[i] = d.ID
+	}
+	var favorites []struct {
+		DeviceID string `db:"device_id"`
+		Count    int64  `db:"count"`
+	}
+	err := r.DB.SelectContext(ctx, &favorites, `
+        SELECT device_id, COUNT(*) AS count FROM favorites
+        WHERE device_id = ANY($1)
+        GROUP BY device_id
+    `, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	var views []struct {
+		DeviceID string `db:"device_id"`
+		Views    int64  `db:"views"`
+	}
+	err = r.DB.SelectContext(ctx, &views, `
+        SELECT device_id, SUM(views) AS views FROM device_daily_stats
+        WHERE device_id = ANY($1)
+        GROUP BY device_id
+    `, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]*model.DeviceStats, len(devices))
+	out := make([]model.OwnerDevice, len(devices))
+	for i, d := range devices {
+		out[i].Device = d
+		out[i].Stats.Views = new(int64)
+		stats[d.ID] = &out[i].Stats
+	}
+	for _, fav := range favorites {
+		stats[fav.DeviceID].Favorites = fav.Count
+	}
+	for _, v := range views {
+		*stats[v.DeviceID].Views = v.Views
+	}
+	return out, nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+
+	"device-service/internal/model"
+)
+
+// Advisory lock key that keeps replicas from rebuilding the model at the same time
+const cooccurrenceLock = 0x6465766963 // "devic"
+
+// RefreshCooccurrence rebuilds device_cooccurrence from favorites, keeping the
+// neighbors best pairs per device. Users with more than maxUserFavorites favorites
+// are left out. Readers see the previous model until the rebuild commits; if another
+// replica is already rebuilding, this call does nothing.
+func (r *DeviceRepository) RefreshCooccurrence(ctx context.Context, neighbors, maxUserFavorites int) (int64, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.RefreshCooccurrence")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, `SELECT pg_try_advisory_xact_lock($1)`, cooccurrenceLock); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM device_cooccurrence`); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `
+        WITH fav AS (
+            SELECT f.user_id, f.device_id
+            FROM favorites f
+            JOIN devices d ON d.id = f.device_id AND d.deleted_at IS NULL
+            WHERE f.user_id IN (
+                SELECT user_id FROM favorites GROUP BY user_id HAVING COUNT(*) BETWEEN 2 AND $2
+            )
+        ),
+        fans AS (
+            SELECT device_id, COUNT(*) AS n FROM fav GROUP BY device_id
+        ),
+        pairs AS (
+            SELECT a.device_id, b.device_id AS similar_id, COUNT(*) AS users
+            FROM fav a
+            JOIN fav b ON b.user_id = a.user_id AND b.device_id <> a.device_id
+            GROUP BY a.device_id, b.device_id
+        ),
+        ranked AS (
+            SELECT p.device_id, p.similar_id, p.users,
+                   p.users / SQRT(CAST(fa.n * fb.n AS DOUBLE PRECISION)) AS score
+            FROM pairs p
+            JOIN fans fa ON fa.device_id = p.device_id
+            JOIN fans fb ON fb.device_id = p.similar_id
+        )
+        INSERT INTO device_cooccurrence (device_id, similar_id, score, users)
+        SELECT device_id, similar_id, score, users
+        FROM (
+            SELECT *, ROW_NUMBER() OVER (PARTITION BY device_id ORDER BY score DESC, users DESC, similar_id) AS rank
+            FROM ranked
+        ) t
+        WHERE rank <= $1
+    `, neighbors, maxUserFavorites)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
+// GetRecommendations suggests published devices of other owners that people with
+// similar favorites have favorited. When the user's history gives fewer than limit,
+// the rest is filled with trending devices.
+func (r *DeviceRepository) GetRecommendations(ctx context.Context, userID string, limit int) ([]model.Recommendation, error) {
+	ctx, span := startSpan(ctx, "DeviceRepository.GetRecommendations")
+	defer span.End()
+
+	var devices []model.Device
+	err := r.DB.SelectContext(ctx, &devices, `
+        SELECT d.*
+        FROM (
+            SELECT c.similar_id, SUM(c.score) AS score
+            FROM favorites f
+            JOIN device_cooccurrence c ON c.device_id = f.device_id
+            WHERE f.user_id = $1
+            GROUP BY c.similar_id
+        ) r
+        JOIN devices d ON d.id = r.similar_id
+        WHERE d.deleted_at IS NULL AND d.status = 'published' AND d.owner_id <> $1
+          AND NOT EXISTS (SELECT 1 FROM favorites f WHERE f.user_id = $1 AND f.device_id = d.id)
+        ORDER BY r.score DESC, d.id
+        LIMIT $2
+    `, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([]model.Recommendation, 0, limit)
+	seen := make(map[string]bool, limit)
+	for _, d := range devices {
+		recs = append(recs, model.Recommendation{Device: d, Source: model.RecommendationFavorites})
+		seen[d.ID] = true
+	}
+	if len(recs) == limit {
+		return recs, nil
+	}
+
+	// Not enough history (e.g. no favorites yet): fall back to what is popular,
+	// minus the user's own devices and favorites
+	var favorites []string
+	if err := r.DB.SelectContext(ctx, &favorites, `SELECT device_id FROM favorites WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+	for _, id := range favorites {
+		seen[id] = true
+	}
+	popular, err := r.GetTrendingDevices(ctx, model.TrendingQuery{Limit: limit + len(seen)})
+	if err != nil {
+		return nil, err
+	}
+	return fillRecommendations(recs, popular, seen, userID, limit), nil
+}
+
+// fillRecommendations tops recs up to limit with popular devices that are not in seen
+// and not owned by userID
+func fillRecommendations(recs []model.Recommendation, popular []model.Device, seen map[string]bool, userID string, limit int) []model.Recommendation {
+	for _, d := range popular {
+		if len(recs) == limit {
+			break
+		}
+		if seen[d.ID] || d.OwnerID == userID {
+			continue
+		}
+		recs = append(recs, model.Recommendation{Device: d, Source: model.RecommendationTrending})
+		seen[d.ID] = true
+	}
+	return recs
+}
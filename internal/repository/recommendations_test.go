@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"device-service/internal/model"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+func TestFillRecommendations(t *testing.T) {
+	recs := []model.Recommendation{{Device: model.Device{ID: "a"}, Source: model.RecommendationFavorites}}
+	seen := map[string]bool{"a": true, "fav": true}
+	popular := []model.Device{
+		{ID: "a", OwnerID: "o"},   // already recommended
+		{ID: "fav", OwnerID: "o"}, // already a favorite
+		{ID: "own", OwnerID: "u1"},
+		{ID: "b", OwnerID: "o"},
+		{ID: "b", OwnerID: "o"}, // trending pages may repeat a device
+		{ID: "c", OwnerID: "o"},
+		{ID: "d", OwnerID: "o"},
+	}
+
+	got := fillRecommendations(recs, popular, seen, "u1", 3)
+	var ids []string
+	for _, r := range got {
+		ids = append(ids, r.ID+":"+r.Source)
+	}
+	want := "a:" + model.RecommendationFavorites + ",b:" + model.RecommendationTrending + ",c:" + model.RecommendationTrending
+	if strings.Join(ids, ",") != want {
+		t.Errorf("got %v, want %s", ids, want)
+	}
+
+	if got := fillRecommendations(nil, nil, map[string]bool{}, "u1", 3); len(got) != 0 {
+		t.Errorf("got %v from nothing", got)
+	}
+}
+
+// testDB opens TEST_DATABASE_URL in a fresh schema with minimal devices and favorites
+// tables plus the given migrations, or skips the test when no database is configured
+func testDB(t *testing.T, migrations ...string) *sqlx.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sqlx.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("repository_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	db, err := sqlx.Open("postgres", url+sep+"search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// Only the columns the tested queries use
+	_, err = db.Exec(`
+        CREATE TABLE devices (id UUID PRIMARY KEY, deleted_at TIMESTAMPTZ);
+        CREATE TABLE favorites (user_id TEXT NOT NULL, device_id UUID NOT NULL REFERENCES devices (id), PRIMARY KEY (user_id, device_id));
+    `)
+	if err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+	for _, m := range migrations {
+		ddl, err := os.ReadFile("../../migrations/" + m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(ddl)); err != nil {
+			t.Fatalf("apply %s: %v", m, err)
+		}
+	}
+	return db
+}
+
+func deviceUUID(name string) string {
+	return "00000000-0000-0000-0000-00000000000" + name
+}
+
+func TestRefreshCooccurrence(t *testing.T) {
+	db := testDB(t, "010_device_cooccurrence.sql")
+	ctx := context.Background()
+
+	for _, d := range []string{"a", "b", "c", "d"} {
+		db.MustExec(`INSERT INTO devices (id) VALUES ($1)`, deviceUUID(d))
+	}
+	db.MustExec(`INSERT INTO devices (id, deleted_at) VALUES ($1, NOW())`, deviceUUID("e"))
+	favorites := map[string]string{
+		"u1": "abe", // e is in the trash and doesn't count
+		"u2": "abc",
+		"u3": "ac",
+		"u4": "abcd", // more than maxUserFavorites: left out
+		"u5": "d",    // a single favorite pairs with nothing
+	}
+	for user, devices := range favorites {
+		for _, d := range devices {
+			db.MustExec(`INSERT INTO favorites (user_id, device_id) VALUES ($1, $2)`, user, deviceUUID(string(d)))
+		}
+	}
+
+	repo := &DeviceRepository{DB: db}
+	type pair struct {
+		DeviceID  string  `db:"device_id"`
+		SimilarID string  `db:"similar_id"`
+		Score     float64 `db:"score"`
+		Users     int     `db:"users"`
+	}
+	load := func() map[string]pair {
+		var rows []pair
+		if err := db.Select(&rows, `SELECT * FROM device_cooccurrence`); err != nil {
+			t.Fatal(err)
+		}
+		byPair := map[string]pair{}
+		for _, p := range rows {
+			byPair[p.DeviceID[len(p.DeviceID)-1:]+p.SimilarID[len(p.SimilarID)-1:]] = p
+		}
+		return byPair
+	}
+
+	// Fans: a 3, b 2, c 2; together: ab 2, ac 2, bc 1
+	n, err := repo.RefreshCooccurrence(ctx, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Errorf("stored %d pairs, want 6", n)
+	}
+	want := map[string]struct {
+		score float64
+		users int
+	}{
+		"ab": {2 / math.Sqrt(6), 2}, "ba": {2 / math.Sqrt(6), 2},
+		"ac": {2 / math.Sqrt(6), 2}, "ca": {2 / math.Sqrt(6), 2},
+		"bc": {0.5, 1}, "cb": {0.5, 1},
+	}
+	got := load()
+	for key, w := range want {
+		p, ok := got[key]
+		if !ok {
+			t.Errorf("pair %s missing", key)
+			continue
+		}
+		if math.Abs(p.Score-w.score) > 1e-9 || p.Users != w.users {
+			t.Errorf("pair %s: score %v users %d, want %v and %d", key, p.Score, p.Users, w.score, w.users)
+		}
+	}
+
+	// One neighbor each; a's tie between b and c goes to the lower ID.
+	// The rebuild replaces the previous model.
+	if _, err := repo.RefreshCooccurrence(ctx, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	got = load()
+	if len(got) != 3 {
+		t.Errorf("got %d pairs, want 3", len(got))
+	}
+	for _, key := range []string{"ab", "ba", "ca"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("pair %s missing from %v", key, got)
+		}
+	}
+}
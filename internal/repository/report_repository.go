@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"device-service/internal/audit"
+	"device-service/internal/domainerr"
+	"device-service/internal/metrics"
+	"device-service/internal/model"
+	"device-service/internal/moderation"
+
+	"github.com/goccy/go-json"
+	"github.com/jmoiron/sqlx"
+)
+
+type ReportRepository struct {
+	DB         *sqlx.DB
+	Moderation *moderation.Checker
+
+	// HideThreshold open reports from different users hide a listing pending review
+	HideThreshold int
+}
+
+func NewReportRepository(db *sqlx.DB, checker *moderation.Checker, hideThreshold int) *ReportRepository {
+	return &ReportRepository{DB: db, Moderation: checker, HideThreshold: hideThreshold}
+}
+
+// ReportedDevice is a device with open reports, as listed for admins
+type ReportedDevice struct {
+	DeviceID        string          `db:"device_id" json:"device_id"`
+	OpenReports     int             `db:"open_reports" json:"open_reports"`
+	Reasons         json.RawMessage `db:"reasons" json:"reasons"` // reason → count
+	FirstReportedAt time.Time       `db:"first_reported_at" json:"first_reported_at"`
+	LastReportedAt  time.Time       `db:"last_reported_at" json:"last_reported_at"`
+	Device          *model.Device   `db:"-" json:"device"`
+}
+
+// CreateReport files a report about a published device. A user can have one open
+// report per device. Once HideThreshold users have open reports, the device is taken
+// out of the catalog (pending_review) and queued for moderation.
+func (r *ReportRepository) CreateReport(ctx context.Context, deviceID, reporterID string, in model.ReportInput) (*model.Report, error) {
+	ctx, span := startSpan(ctx, "ReportRepository.CreateReport")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The lock also serializes reports of one device, so the threshold is crossed once
+	device, err := lockForWrite(ctx, tx, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReportable(device, reporterID); err != nil {
+		return nil, err
+	}
+
+	var report model.Report
+	err = tx.GetContext(ctx, &report, `
+        INSERT INTO device_reports (device_id, reporter_id, reason, comment)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (device_id, reporter_id) WHERE status = 'open' DO NOTHING
+        RETURNING *
+    `, deviceID, reporterID, in.Reason, in.Comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domainerr.Conflict("you have already reported this device")
+	}
+	if err != nil {
+		return nil, translateError(err, "device")
+	}
+
+	var open int
+	err = tx.GetContext(ctx, &open,
+		`SELECT COUNT(*) FROM device_reports WHERE device_id = $1 AND status = 'open'`, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if r.hides(open) {
+		// The owner sees the history, so the hide is not attributed to the reporter
+		reason := fmt.Sprintf("hidden after %d user reports", open)
+		hidden, err := setStatus(ctx, tx, device, model.StatusPendingReview, audit.ActorSystem, reason)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := enqueueModeration(ctx, tx, r.Moderation, hidden, model.ModerationReport); err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "device hidden after user reports", "device_id", deviceID, "open_reports", open)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	metrics.DeviceReports.WithLabelValues(in.Reason).Inc()
+	return &report, nil
+}
+
+// ListReportedDevices returns up to limit devices with open reports, most reported first
+func (r *ReportRepository) ListReportedDevices(ctx context.Context, limit int) ([]ReportedDevice, error) {
+	ctx, span := startSpan(ctx, "ReportRepository.ListReportedDevices")
+	defer span.End()
+
+	reported := []ReportedDevice{}
+	err := r.DB.SelectContext(ctx, &reported, `
+        SELECT device_id, COUNT(*) AS open_reports,
+               MIN(created_at) AS first_reported_at, MAX(created_at) AS last_reported_at,
+               (SELECT jsonb_object_agg(reason, n) FROM (
+                    SELECT reason, COUNT(*) AS n FROM device_reports r2
+                    WHERE r2.device_id = r.device_id AND r2.status = 'open'
+                    GROUP BY reason
+               ) counts) AS reasons
+        FROM device_reports r
+        WHERE status = 'open'
+        GROUP BY device_id
+        ORDER BY open_reports DESC, first_reported_at
+        LIMIT $1
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(reported))
+	for i, rd := range reported {
+		ids[i] = rd.DeviceID
+	}
+	byID, err := devicesByID(ctx, r.DB, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reported {
+		reported[i].Device = byID[reported[i].DeviceID]
+	}
+	return reported, nil
+}
+
+// GetDeviceReports returns the reports of a device, newest first; status "" means all
+func (r *ReportRepository) GetDeviceReports(ctx context.Context, deviceID, status string) ([]model.Report, error) {
+	ctx, span := startSpan(ctx, "ReportRepository.GetDeviceReports")
+	defer span.End()
+
+	reports := []model.Report{}
+	err := r.DB.SelectContext(ctx, &reports, `
+        SELECT * FROM device_reports
+        WHERE device_id = $1 AND ($2 = '' OR status = $2)
+        ORDER BY id DESC
+    `, deviceID, status)
+	return reports, translateError(err, "device")
+}
+
+// ResolveReports closes every open report of the device. Upheld reports archive the
+// device; dismissed ones put a device hidden by reports back in the catalog. A pending
+// moderation item (normally opened by the reports) is decided the same way.
+// Returns how many reports were resolved.
+func (r *ReportRepository) ResolveReports(ctx context.Context, deviceID, adminID, resolution, note string) (int64, error) {
+	ctx, span := startSpan(ctx, "ReportRepository.ResolveReports")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	device, err := lockForWrite(ctx, tx, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := resolveReports(ctx, tx, deviceID, resolution, adminID, note)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, domainerr.Conflict("device has no open reports")
+	}
+
+	// The open item is normally the one the reports opened; an older item of another
+	// kind is decided the same way, so nothing is left pending on a resolved device
+	var item model.ModerationItem
+	err = tx.GetContext(ctx, &item, `
+        SELECT * FROM moderation_items
+        WHERE device_id = $1 AND status = 'pending'
+        FOR UPDATE
+    `, deviceID)
+	switch {
+	case err == nil:
+		if err := decideItem(ctx, tx, &item, device, reportDecision(resolution), adminID, note); err != nil {
+			return 0, err
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		to, err := resolvedStatus(device, resolution, func() (string, error) {
+			return statusBeforeReportHide(ctx, tx, device)
+		})
+		if err != nil {
+			return 0, err
+		}
+		if to != "" {
+			if _, err := setStatus(ctx, tx, device, to, adminID, note); err != nil {
+				return 0, err
+			}
+		}
+	default:
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// checkReportable reports why reporterID can't report the device, or nil if they can.
+// Only listed devices can be reported; others look missing, as in the catalog.
+func checkReportable(device *model.Device, reporterID string) error {
+	if device.Status != model.StatusPublished {
+		return domainerr.NotFound("device not found")
+	}
+	if device.OwnerID == reporterID {
+		return domainerr.Forbidden("you cannot report your own device")
+	}
+	return nil
+}
+
+// hides reports whether open reports are enough to take a device out of the catalog
+func (r *ReportRepository) hides(open int) bool {
+	return open >= r.HideThreshold
+}
+
+// reportDecision is how resolving reports decides the device's pending moderation
+// item; the inverse of reportResolution
+func reportDecision(resolution string) string {
+	if resolution == model.ReportUpheld {
+		return model.ModerationRejected
+	}
+	return model.ModerationApproved
+}
+
+// resolvedStatus is the status a device without a pending moderation item moves to
+// when its reports are resolved, or "" to leave it. beforeHide looks up the status
+// reports hid it from (see statusBeforeReportHide); it is only called on dismissal.
+func resolvedStatus(device *model.Device, resolution string, beforeHide func() (string, error)) (string, error) {
+	switch resolution {
+	case model.ReportUpheld:
+		if device.Status == model.StatusArchived {
+			return "", nil
+		}
+		return model.StatusArchived, nil
+	case model.ReportDismissed:
+		return beforeHide()
+	default:
+		return "", nil
+	}
+}
+
+// statusBeforeReportHide returns the status the device had before user reports hid it,
+// or "" if its current status was not set by the reports (or it changed since)
+func statusBeforeReportHide(ctx context.Context, tx *sqlx.Tx, device *model.Device) (string, error) {
+	var status string
+	err := tx.GetContext(ctx, &status, `
+        SELECT before->>'status' FROM device_audit
+        WHERE device_id = $1 AND version = $2 AND action = $3 AND actor_id = $4
+          AND after->>'status' = 'pending_review'
+        ORDER BY id DESC
+        LIMIT 1
+    `, device.ID, device.Version, audit.ActionStatus, audit.ActorSystem)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return status, err
+}
+
+// resolveReports closes the open reports of a device in tx; returns how many
+func resolveReports(ctx context.Context, tx *sqlx.Tx, deviceID, resolution, resolvedBy, note string) (int64, error) {
+	res, err := tx.ExecContext(ctx, `
+        UPDATE device_reports
+        SET status = $1, resolution_note = NULLIF($2, ''), resolved_by = $3, resolved_at = NOW()
+        WHERE device_id = $4 AND status = 'open'
+    `, resolution, note, resolvedBy, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
+)
+
+func TestCheckReportable(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		reporter string
+		want     error
+	}{
+		{"published", model.StatusPublished, "reporter", nil},
+		{"own device", model.StatusPublished, "owner", domainerr.ErrForbidden},
+		{"draft", model.StatusDraft, "reporter", domainerr.ErrNotFound},
+		{"already hidden", model.StatusPendingReview, "reporter", domainerr.ErrNotFound},
+		{"archived", model.StatusArchived, "reporter", domainerr.ErrNotFound},
+		// An unlisted device must not reveal who owns it
+		{"own draft", model.StatusDraft, "owner", domainerr.ErrNotFound},
+	}
+	for _, tt := range tests {
+		err := checkReportable(&model.Device{OwnerID: "owner", Status: tt.status}, tt.reporter)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want kind of %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestHideThreshold(t *testing.T) {
+	r := &ReportRepository{HideThreshold: 3}
+	for open, want := range map[int]bool{1: false, 2: false, 3: true, 4: true} {
+		if got := r.hides(open); got != want {
+			t.Errorf("hides(%d) = %v with threshold 3", open, got)
+		}
+	}
+}
+
+func TestReportDecisionRoundTrip(t *testing.T) {
+	// Resolving reports and deciding the report item must agree in both directions
+	for _, resolution := range []string{model.ReportUpheld, model.ReportDismissed} {
+		if got := reportResolution(reportDecision(resolution)); got != resolution {
+			t.Errorf("%s → %s → %s", resolution, reportDecision(resolution), got)
+		}
+	}
+}
+
+func TestResolvedStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		resolution string
+		beforeHide string
+		want       string
+		lookup     bool
+	}{
+		{"upheld", model.StatusPendingReview, model.ReportUpheld, "", model.StatusArchived, false},
+		{"upheld while published", model.StatusPublished, model.ReportUpheld, "", model.StatusArchived, false},
+		{"upheld archived", model.StatusArchived, model.ReportUpheld, "", "", false},
+		{"dismissed after hide", model.StatusPendingReview, model.ReportDismissed, model.StatusPublished, model.StatusPublished, true},
+		{"dismissed, changed since", model.StatusDraft, model.ReportDismissed, "", "", true},
+	}
+	for _, tt := range tests {
+		looked := false
+		got, err := resolvedStatus(&model.Device{Status: tt.status}, tt.resolution, func() (string, error) {
+			looked = true
+			return tt.beforeHide, nil
+		})
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+		if looked != tt.lookup {
+			t.Errorf("%s: looked up the status before the hide: %v", tt.name, looked)
+		}
+	}
+
+	lookupErr := errors.New("connection reset")
+	_, err := resolvedStatus(&model.Device{Status: model.StatusPendingReview}, model.ReportDismissed, func() (string, error) {
+		return "", lookupErr
+	})
+	if !errors.Is(err, lookupErr) {
+		t.Errorf("got %v, want the lookup error", err)
+	}
+}
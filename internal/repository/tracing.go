@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"device-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("device-service/internal/repository")
+
+// startSpan opens a span for a repository method. The SQL statements it runs
+// get child spans from the otelsql driver wrapper, Redis calls from redisotel.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name)
+}
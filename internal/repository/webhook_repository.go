@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type WebhookRepository struct {
+	DB *sqlx.DB
+}
+
+func NewWebhookRepository(db *sqlx.DB) *WebhookRepository {
+	return &WebhookRepository{DB: db}
+}
+
+// DueDelivery is a claimed delivery together with where and how to send it
+type DueDelivery struct {
+	model.WebhookDelivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// CreateSubscription stores s; id, timestamps and status are filled from the DB
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, s *model.WebhookSubscription) error {
+	ctx, span := startSpan(ctx, "WebhookRepository.CreateSubscription")
+	defer span.End()
+
+	err := r.DB.GetContext(ctx, s, `
+        INSERT INTO webhook_subscriptions (owner_id, url, event_types, secret)
+        VALUES ($1, $2, $3, $4)
+        RETURNING *
+    `, s.OwnerID, s.URL, s.EventTypes, s.Secret)
+	return translateError(err, "webhook")
+}
+
+// ListSubscriptions returns the owner's subscriptions, secrets stripped
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context, ownerID string) ([]model.WebhookSubscription, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.ListSubscriptions")
+	defer span.End()
+
+	subs := []model.WebhookSubscription{}
+	err := r.DB.SelectContext(ctx, &subs,
+		`SELECT * FROM webhook_subscriptions WHERE owner_id = $1 ORDER BY created_at`, ownerID)
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, err
+}
+
+// GetSubscription returns the subscription if it belongs to ownerID, secret stripped
+func (r *WebhookRepository) GetSubscription(ctx context.Context, id, ownerID string) (*model.WebhookSubscription, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.GetSubscription")
+	defer span.End()
+
+	var s model.WebhookSubscription
+	if err := r.DB.GetContext(ctx, &s, `SELECT * FROM webhook_subscriptions WHERE id = $1`, id); err != nil {
+		return nil, translateError(err, "webhook")
+	}
+	if s.OwnerID != ownerID {
+		return nil, domainerr.Forbidden("webhook belongs to another user")
+	}
+	s.Secret = ""
+	return &s, nil
+}
+
+// UpdateSubscription changes URL, event types and, if set, the active flag.
+// Re-activating a subscription clears its failure counter.
+func (r *WebhookRepository) UpdateSubscription(ctx context.Context, id, ownerID string, in model.WebhookInput) (*model.WebhookSubscription, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.UpdateSubscription")
+	defer span.End()
+
+	if _, err := r.GetSubscription(ctx, id, ownerID); err != nil {
+		return nil, err
+	}
+
+	var s model.WebhookSubscription
+	err := r.DB.GetContext(ctx, &s, `
+        UPDATE webhook_subscriptions
+        SET url = $3, event_types = $4,
+            active = COALESCE($5, active),
+            consecutive_failures = CASE WHEN $5 THEN 0 ELSE consecutive_failures END,
+            disabled_at = CASE WHEN $5 THEN NULL WHEN NOT $5 THEN COALESCE(disabled_at, NOW()) ELSE disabled_at END,
+            disabled_reason = CASE WHEN $5 THEN NULL WHEN NOT $5 THEN 'disabled by owner' ELSE disabled_reason END,
+            updated_at = NOW()
+        WHERE id = $1 AND owner_id = $2
+        RETURNING *
+    `, id, ownerID, in.URL, pq.StringArray(in.EventTypes), in.Active)
+	if err != nil {
+		return nil, translateError(err, "webhook")
+	}
+	s.Secret = ""
+	return &s, nil
+}
+
+// RotateSecret replaces the signing secret
+func (r *WebhookRepository) RotateSecret(ctx context.Context, id, ownerID, secret string) (*model.WebhookSubscription, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.RotateSecret")
+	defer span.End()
+
+	if _, err := r.GetSubscription(ctx, id, ownerID); err != nil {
+		return nil, err
+	}
+
+	var s model.WebhookSubscription
+	err := r.DB.GetContext(ctx, &s, `
+        UPDATE webhook_subscriptions SET secret = $3, updated_at = NOW()
+        WHERE id = $1 AND owner_id = $2
+        RETURNING *
+    `, id, ownerID, secret)
+	if err != nil {
+		return nil, translateError(err, "webhook")
+	}
+	return &s, nil
+}
+
+// DeleteSubscription removes the subscription and its delivery log
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id, ownerID string) error {
+	ctx, span := startSpan(ctx, "WebhookRepository.DeleteSubscription")
+	defer span.End()
+
+	if _, err := r.GetSubscription(ctx, id, ownerID); err != nil {
+		return err
+	}
+	_, err := r.DB.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1 AND owner_id = $2`, id, ownerID)
+	return translateError(err, "webhook")
+}
+
+// ListDeliveries returns the latest deliveries of a subscription, newest first
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]model.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.ListDeliveries")
+	defer span.End()
+
+	deliveries := []model.WebhookDelivery{}
+	err := r.DB.SelectContext(ctx, &deliveries, `
+        SELECT * FROM webhook_deliveries
+        WHERE subscription_id = $1
+        ORDER BY id DESC
+        LIMIT $2
+    `, subscriptionID, limit)
+	return deliveries, err
+}
+
+// ReplayDelivery schedules a delivery to be sent again right away, whatever its status
+func (r *WebhookRepository) ReplayDelivery(ctx context.Context, subscriptionID string, deliveryID int64) (*model.WebhookDelivery, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.ReplayDelivery")
+	defer span.End()
+
+	var d model.WebhookDelivery
+	err := r.DB.GetContext(ctx, &d, `
+        UPDATE webhook_deliveries
+        SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+        WHERE id = $1 AND subscription_id = $2
+        RETURNING *
+    `, deliveryID, subscriptionID)
+	if err != nil {
+		return nil, translateError(err, "delivery")
+	}
+	return &d, nil
+}
+
+// EnqueueDeliveries creates a pending delivery of the event for every active subscription
+// of ownerID that listens to eventType. Safe to call again for the same event.
+func (r *WebhookRepository) EnqueueDeliveries(ctx context.Context, ownerID string, eventID int64, eventType string, body []byte) (int64, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.EnqueueDeliveries")
+	defer span.End()
+
+	res, err := r.DB.ExecContext(ctx, `
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+        SELECT id, $2, $3, $4 FROM webhook_subscriptions
+        WHERE owner_id = $1 AND active AND $3 = ANY(event_types)
+        ON CONFLICT (subscription_id, event_id) DO NOTHING
+    `, ownerID, eventID, eventType, body)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ClaimDueDeliveries leases up to limit due deliveries of active subscriptions: they are
+// hidden from other dispatchers for lease, and come back if the process dies mid-send.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.ClaimDueDeliveries")
+	defer span.End()
+
+	var due []DueDelivery
+	err := r.DB.SelectContext(ctx, &due, `
+        WITH claimed AS (
+            UPDATE webhook_deliveries
+            SET next_attempt_at = NOW() + CAST($2 AS DOUBLE PRECISION) * INTERVAL '1 second'
+            WHERE id IN (
+                SELECT d.id FROM webhook_deliveries d
+                JOIN webhook_subscriptions s ON s.id = d.subscription_id
+                WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND s.active
+                ORDER BY d.id
+                LIMIT $1
+                FOR UPDATE OF d SKIP LOCKED
+            )
+            RETURNING *
+        )
+        SELECT c.*, s.url, s.secret
+        FROM claimed c JOIN webhook_subscriptions s ON s.id = c.subscription_id
+        ORDER BY c.id
+    `, limit, lease.Seconds())
+	return due, err
+}
+
+// MarkDelivered records a 2xx answer and resets the subscription's failure counter
+func (r *WebhookRepository) MarkDelivered(ctx context.Context, d DueDelivery, statusCode int) error {
+	ctx, span := startSpan(ctx, "WebhookRepository.MarkDelivered")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET status = 'succeeded', attempts = attempts + 1, last_status_code = $2,
+            last_error = NULL, delivered_at = NOW()
+        WHERE id = $1
+    `, d.ID, statusCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE webhook_subscriptions SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures <> 0`,
+		d.SubscriptionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkFailed records a failed attempt. retryIn 0 gives up on the delivery. The subscription is
+// disabled once it has failed disableAfter times in a row; it reports whether that happened.
+func (r *WebhookRepository) MarkFailed(ctx context.Context, d DueDelivery, statusCode *int, reason string, retryIn time.Duration, disableAfter int) (bool, error) {
+	ctx, span := startSpan(ctx, "WebhookRepository.MarkFailed")
+	defer span.End()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	status := model.DeliveryPending
+	if retryIn == 0 {
+		status = model.DeliveryFailed
+	}
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = $4,
+            next_attempt_at = NOW() + CAST($5 AS DOUBLE PRECISION) * INTERVAL '1 second'
+        WHERE id = $1
+    `, d.ID, status, statusCode, reason, retryIn.Seconds()); err != nil {
+		return false, err
+	}
+
+	// Only the failure that reaches the threshold disables, so this reports it once
+	var disabled bool
+	if err := tx.GetContext(ctx, &disabled, `
+        UPDATE webhook_subscriptions
+        SET consecutive_failures = consecutive_failures + 1,
+            active = active AND consecutive_failures + 1 < $2,
+            disabled_at = CASE WHEN consecutive_failures + 1 = $2 THEN NOW() ELSE disabled_at END,
+            disabled_reason = CASE WHEN consecutive_failures + 1 = $2
+                THEN 'disabled after ' || $2 || ' consecutive failed deliveries' ELSE disabled_reason END
+        WHERE id = $1
+        RETURNING consecutive_failures = $2
+    `, d.SubscriptionID, disableAfter); err != nil {
+		return false, err
+	}
+	return disabled, tx.Commit()
+}
@@ -0,0 +1,115 @@
+// Package similar ranks devices by how much they resemble a given one, for the
+// "similar devices nearby" block of the device page.
+package similar
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"device-service/internal/model"
+	"device-service/internal/outbox"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Weights of the signals; each signal itself is between 0 and 1
+const (
+	weightCategory = 3
+	weightCity     = 2
+	weightRegion   = 1
+	weightPrice    = 2
+	weightText     = 3
+)
+
+// PoolSize is how many candidates are loaded from the database and scored
+const PoolSize = 200
+
+// Rank orders candidates by similarity to src, best first, and keeps up to limit.
+// Candidates with nothing in common with src are dropped.
+func Rank(src *model.Device, candidates []model.Device, limit int) []model.Device {
+	srcTokens := tokens(src.Name + " " + src.Description)
+
+	type scored struct {
+		device model.Device
+		score  float64
+	}
+	ranked := make([]scored, 0, len(candidates))
+	for _, d := range candidates {
+		if s := score(src, srcTokens, &d); s > 0 {
+			ranked = append(ranked, scored{d, s})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	devices := make([]model.Device, 0, min(limit, len(ranked)))
+	for _, r := range ranked[:min(limit, len(ranked))] {
+		devices = append(devices, r.device)
+	}
+	return devices
+}
+
+func score(src *model.Device, srcTokens map[string]struct{}, d *model.Device) float64 {
+	var s float64
+	if d.Category == src.Category {
+		s += weightCategory
+	}
+	if src.City != "" && strings.EqualFold(d.City, src.City) {
+		s += weightCity
+	}
+	if src.Region != "" && strings.EqualFold(d.Region, src.Region) {
+		s += weightRegion
+	}
+	if hi := math.Max(d.PricePerDay, src.PricePerDay); hi > 0 {
+		s += weightPrice * (1 - math.Abs(d.PricePerDay-src.PricePerDay)/hi)
+	}
+	s += weightText * jaccard(srcTokens, tokens(d.Name+" "+d.Description))
+	return s
+}
+
+// tokens splits text into lowercase words of at least two letters or digits
+func tokens(text string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 2 {
+			set[w] = struct{}{}
+		}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for w := range a {
+		if _, ok := b[w]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// GenerationKey holds a counter that is part of every cache key of similar lists.
+// Any device change can move a device into or out of any list, so instead of
+// finding the affected keys the counter is bumped and old entries just expire.
+const GenerationKey = "similar:gen"
+
+// Invalidator is the outbox sink that bumps GenerationKey on every device event
+type Invalidator struct {
+	Redis redis.UniversalClient
+}
+
+func (s *Invalidator) Name() string { return "similar-cache" }
+
+func (s *Invalidator) Publish(ctx context.Context, ev outbox.Event) error {
+	if !strings.HasPrefix(ev.Type, "device.") {
+		return nil
+	}
+	return s.Redis.Incr(ctx, GenerationKey).Err()
+}
@@ -0,0 +1,116 @@
+package similar
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"device-service/internal/model"
+	"device-service/internal/outbox"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func ids(devices []model.Device) string {
+	out := make([]string, len(devices))
+	for i, d := range devices {
+		out[i] = d.ID
+	}
+	return strings.Join(out, ",")
+}
+
+func TestRank(t *testing.T) {
+	src := &model.Device{ID: "src", Name: "Canon EOS camera", Category: "cameras", PricePerDay: 20, City: "Moscow", Region: "Moscow"}
+	candidates := []model.Device{
+		{ID: "other-category", Name: "Drill", Category: "tools", PricePerDay: 20},
+		{ID: "same-everything", Name: "Canon EOS camera body", Category: "cameras", PricePerDay: 20, City: "moscow", Region: "Moscow"},
+		{ID: "nothing-in-common", Name: "Tent", Category: "camping"},
+		{ID: "same-category", Name: "Sony", Category: "cameras", PricePerDay: 40},
+		{ID: "same-city", Name: "Sony", Category: "cameras", PricePerDay: 40, City: "Moscow"},
+	}
+
+	if got := ids(Rank(src, candidates, 10)); got != "same-everything,same-city,same-category,other-category" {
+		t.Errorf("got %s", got)
+	}
+	if got := ids(Rank(src, candidates, 2)); got != "same-everything,same-city" {
+		t.Errorf("limit 2: got %s", got)
+	}
+	if got := Rank(src, nil, 5); len(got) != 0 {
+		t.Errorf("no candidates: got %v", got)
+	}
+}
+
+func TestRankKeepsCandidateOrderOnTies(t *testing.T) {
+	src := &model.Device{Name: "Projector", Category: "electronics", PricePerDay: 10}
+	candidates := []model.Device{
+		{ID: "a", Name: "Speaker", Category: "electronics", PricePerDay: 10},
+		{ID: "b", Name: "Speaker", Category: "electronics", PricePerDay: 10},
+		{ID: "c", Name: "Speaker", Category: "electronics", PricePerDay: 10},
+	}
+	if got := ids(Rank(src, candidates, 3)); got != "a,b,c" {
+		t.Errorf("got %s, want the input order", got)
+	}
+}
+
+func TestScoreIgnoresOwner(t *testing.T) {
+	src := &model.Device{Name: "Camera", Category: "cameras", PricePerDay: 20, OwnerID: "u1"}
+	d := model.Device{Name: "Camera", Category: "cameras", PricePerDay: 30, OwnerID: "u1"}
+	srcTokens := tokens(src.Name)
+	same := score(src, srcTokens, &d)
+	d.OwnerID = "u2"
+	if other := score(src, srcTokens, &d); other != same {
+		t.Errorf("score depends on the owner: %v vs %v", same, other)
+	}
+}
+
+func TestScore(t *testing.T) {
+	src := &model.Device{Name: "Canon camera", Category: "cameras", PricePerDay: 20, City: "Kazan", Region: "Tatarstan"}
+	d := model.Device{Name: "Canon lens", Category: "cameras", PricePerDay: 10, City: "KAZAN", Region: "Tatarstan"}
+	// category 3 + city 2 + region 1 + price 2·(1-10/20) + text 3·(1/3)
+	want := 3.0 + 2 + 1 + 1 + 1
+	if got := score(src, tokens(src.Name+" "+src.Description), &d); math.Abs(got-want) > 1e-9 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	got := tokens("iPhone 15 Pro, 256GB — как новый! a")
+	want := map[string]struct{}{"iphone": {}, "15": {}, "pro": {}, "256gb": {}, "как": {}, "новый": {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"canon camera", "canon camera", 1},
+		{"canon camera", "canon lens", 1.0 / 3},
+		{"canon camera", "drill", 0},
+		{"", "drill", 0},
+		{"", "", 0},
+	}
+	for _, tt := range tests {
+		if got := jaccard(tokens(tt.a), tokens(tt.b)); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("jaccard(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidatorIgnoresOtherEvents(t *testing.T) {
+	// Nothing listens on this address: a call to Redis would fail the test
+	rdb := redis.NewClient(&redis.Options{Addr: "localhost:1", MaxRetries: -1})
+	defer rdb.Close()
+	s := &Invalidator{Redis: rdb}
+
+	if err := s.Publish(context.Background(), outbox.Event{Type: outbox.FavoriteAdded}); err != nil {
+		t.Errorf("favorite event: %v", err)
+	}
+	if err := s.Publish(context.Background(), outbox.Event{Type: outbox.DeviceUpdated}); err == nil {
+		t.Error("device event: expected the generation bump to reach Redis")
+	}
+}
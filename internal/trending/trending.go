@@ -0,0 +1,169 @@
+// Package trending ranks published devices by recent activity. A background job
+// scores every device from device_daily_stats (favorites and views, decayed by age)
+// and stores the top devices of each window and scope as Redis sorted sets; requests
+// only read the sorted sets. Bookings are not part of this service, so they are not
+// a signal.
+package trending
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// Window is a trending period. Lookback is how many whole days before today are read;
+// a day's activity loses half its weight every HalfLife.
+type Window struct {
+	Name     string
+	Lookback int
+	HalfLife time.Duration
+}
+
+// Windows are the supported values of ?window=
+var Windows = []Window{
+	{Name: "24h", Lookback: 1, HalfLife: 12 * time.Hour},
+	{Name: "7d", Lookback: 6, HalfLife: 2 * 24 * time.Hour},
+	{Name: "30d", Lookback: 29, HalfLife: 7 * 24 * time.Hour},
+}
+
+// DefaultWindow is used when the query leaves the window empty
+const DefaultWindow = "7d"
+
+// Keys: trending:current holds the generation of the last refresh; every refresh
+// writes a new generation and flips the pointer, so readers never see half a ranking.
+const currentKey = "trending:current"
+
+func readyKey(gen string) string { return "trending:" + gen + ":ready" }
+
+func scopeKey(gen, window, scope string) string {
+	return "trending:" + gen + ":" + window + ":" + scope
+}
+
+// Scope names the subset of the catalog a ranking covers
+func Scope(category, city string) string {
+	switch {
+	case category != "":
+		return "category:" + category
+	case city != "":
+		return "city:" + strings.ToLower(city)
+	default:
+		return "all"
+	}
+}
+
+// Ranker recomputes the rankings
+type Ranker struct {
+	DB    *sqlx.DB
+	Redis redis.UniversalClient
+
+	// Size is how many devices are kept per window and scope
+	Size int
+	// FavoriteWeight and ViewWeight weigh one net favorite and one view
+	FavoriteWeight float64
+	ViewWeight     float64
+	// TTL keeps a generation readable for a while after the next one replaces it
+	TTL time.Duration
+}
+
+type scored struct {
+	DeviceID string  `db:"device_id"`
+	Category string  `db:"category"`
+	City     string  `db:"city"`
+	Score    float64 `db:"score"`
+}
+
+// Refresh scores every published device for every window and publishes the rankings
+func (r *Ranker) Refresh(ctx context.Context) error {
+	gen := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	pipe := r.Redis.Pipeline()
+	for _, w := range Windows {
+		rows, err := r.score(ctx, w)
+		if err != nil {
+			return err
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Score > rows[j].Score })
+
+		scopes := map[string][]redis.Z{}
+		for _, row := range rows {
+			in := []string{"all", Scope(row.Category, "")}
+			if row.City != "" {
+				in = append(in, Scope("", row.City))
+			}
+			for _, scope := range in {
+				if len(scopes[scope]) >= r.Size {
+					continue
+				}
+				scopes[scope] = append(scopes[scope], redis.Z{Score: row.Score, Member: row.DeviceID})
+			}
+		}
+		for scope, members := range scopes {
+			key := scopeKey(gen, w.Name, scope)
+			pipe.ZAdd(ctx, key, members...)
+			pipe.Expire(ctx, key, r.TTL)
+		}
+	}
+	pipe.Set(ctx, readyKey(gen), 1, r.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("store trending rankings: %w", err)
+	}
+	if err := r.Redis.Set(ctx, currentKey, gen, 0).Err(); err != nil {
+		return fmt.Errorf("publish trending rankings: %w", err)
+	}
+	return nil
+}
+
+// score computes the decayed score of every published device with activity in w.
+// Each day's counters are dated to the middle of the day.
+func (r *Ranker) score(ctx context.Context, w Window) ([]scored, error) {
+	var rows []scored
+	err := r.DB.SelectContext(ctx, &rows, `
+        SELECT s.device_id, d.category, COALESCE(d.city, '') AS city,
+               SUM(
+                   (CAST($2 AS DOUBLE PRECISION) * GREATEST(s.favorites_added - s.favorites_removed, 0)
+                    + CAST($3 AS DOUBLE PRECISION) * s.views)
+                   * EXP(-LN(2) * GREATEST(EXTRACT(EPOCH FROM NOW() - ((s.day + INTERVAL '12 hours') AT TIME ZONE 'UTC')), 0)
+                         / CAST($4 AS DOUBLE PRECISION))
+               ) AS score
+        FROM device_daily_stats s
+        JOIN devices d ON d.id = s.device_id
+        WHERE s.day >= CAST(NOW() AT TIME ZONE 'UTC' AS DATE) - CAST($1 AS INTEGER)
+          AND d.status = 'published' AND d.deleted_at IS NULL
+        GROUP BY s.device_id, d.category, d.city
+        HAVING SUM(GREATEST(s.favorites_added - s.favorites_removed, 0) + s.views) > 0
+    `, w.Lookback, r.FavoriteWeight, r.ViewWeight, w.HalfLife.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("score %s trending: %w", w.Name, err)
+	}
+	return rows, nil
+}
+
+// Top returns up to n device IDs of the ranking (all of them if n is 0), best first.
+// ok is false when no ranking has been computed (yet, or for too long), so the caller
+// can fall back.
+func Top(ctx context.Context, rdb redis.UniversalClient, window, scope string, n int) (ids []string, ok bool, err error) {
+	gen, err := rdb.Get(ctx, currentKey).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	pipe := rdb.Pipeline()
+	ready := pipe.Exists(ctx, readyKey(gen))
+	top := pipe.ZRevRange(ctx, scopeKey(gen, window, scope), 0, int64(n-1))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, false, err
+	}
+	if ready.Val() == 0 {
+		return nil, false, nil
+	}
+	return top.Val(), true, nil
+}
@@ -0,0 +1,34 @@
+package trending
+
+import "testing"
+
+func TestScope(t *testing.T) {
+	tests := []struct {
+		category, city, want string
+	}{
+		{"", "", "all"},
+		{"cameras", "", "category:cameras"},
+		{"", "Kazan", "city:kazan"},
+		{"cameras", "Kazan", "category:cameras"}, // the caller filters by city
+	}
+	for _, tt := range tests {
+		if got := Scope(tt.category, tt.city); got != tt.want {
+			t.Errorf("Scope(%q, %q) = %q, want %q", tt.category, tt.city, got, tt.want)
+		}
+	}
+}
+
+func TestWindows(t *testing.T) {
+	found := false
+	for _, w := range Windows {
+		if w.Name == DefaultWindow {
+			found = true
+		}
+		if w.Lookback < 1 || w.HalfLife <= 0 {
+			t.Errorf("window %s: lookback %d, half-life %v", w.Name, w.Lookback, w.HalfLife)
+		}
+	}
+	if !found {
+		t.Errorf("DefaultWindow %q is not one of Windows", DefaultWindow)
+	}
+}
@@ -0,0 +1,201 @@
+// Package validation registers the declarative request rules (the `binding` struct tags
+// on model types) with gin's validator and turns their failures into problem field errors.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"device-service/internal/domainerr"
+	"device-service/internal/model"
+	"device-service/internal/outbox"
+	"device-service/internal/problem"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	gojson "github.com/goccy/go-json"
+)
+
+// Init registers custom rules and field naming on gin's validator.
+// Must be called once before the router starts serving.
+func Init() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("validation: unexpected gin validator engine")
+	}
+
+	// Report fields by their json/query names, not Go names
+	v.RegisterTagNameFunc(fieldName)
+
+	if err := v.RegisterValidation("notblank", notBlank); err != nil {
+		return err
+	}
+	if err := v.RegisterValidation("device_category", deviceCategory); err != nil {
+		return err
+	}
+	if err := v.RegisterValidation("event_type", eventType); err != nil {
+		return err
+	}
+	if err := v.RegisterValidation("report_reason", reportReason); err != nil {
+		return err
+	}
+	v.RegisterStructValidation(deviceFilterRules, model.DeviceFilter{})
+	return nil
+}
+
+// Struct validates v against its binding tags (used where gin doesn't bind for us, e.g. PATCH)
+func Struct(v interface{}) error {
+	return binding.Validator.ValidateStruct(v)
+}
+
+// FieldErrors lists the fields rejected by a Struct error, or nil if err is not one
+func FieldErrors(err error) []domainerr.FieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	fields := make([]domainerr.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, domainerr.FieldError{Field: fe.Field(), Message: message(fe)})
+	}
+	return fields
+}
+
+// Problem converts a bind/validation error into a 400 problem, with per-field errors when possible
+func Problem(err error) *problem.Details {
+	if fields := FieldErrors(err); fields != nil {
+		out := make([]problem.FieldError, 0, len(fields))
+		for _, f := range fields {
+			out = append(out, problem.FieldError{Field: f.Field, Message: f.Message})
+		}
+		return problem.Validation(out)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return problem.Validation([]problem.FieldError{{
+			Field:   typeErr.Field,
+			Message: "must be " + kindName(typeErr.Type.Kind()),
+		}})
+	}
+
+	// goccy/go-json (used by the handlers) names the Go field rather than the JSON one
+	var goTypeErr *gojson.UnmarshalTypeError
+	if errors.As(err, &goTypeErr) && goTypeErr.Field != "" {
+		return problem.Validation([]problem.FieldError{{
+			Field:   goTypeErr.Field,
+			Message: "must be " + kindName(goTypeErr.Type.Kind()),
+		}})
+	}
+
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		// gin's query binding doesn't tell which parameter failed
+		return problem.New(http.StatusBadRequest, fmt.Sprintf("Invalid query parameter value %q", numErr.Num))
+	}
+
+	var syntaxErr *json.SyntaxError
+	var goSyntaxErr *gojson.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.As(err, &goSyntaxErr) {
+		return problem.New(http.StatusBadRequest, "Malformed JSON body")
+	}
+
+	return problem.New(http.StatusBadRequest, err.Error())
+}
+
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+func notBlank(fl validator.FieldLevel) bool {
+	return strings.TrimSpace(fl.Field().String()) != ""
+}
+
+func deviceCategory(fl validator.FieldLevel) bool {
+	return model.IsDeviceCategory(fl.Field().String())
+}
+
+func eventType(fl validator.FieldLevel) bool {
+	return outbox.IsEventType(fl.Field().String())
+}
+
+func reportReason(fl validator.FieldLevel) bool {
+	return model.IsReportReason(fl.Field().String())
+}
+
+// deviceFilterRules holds the cross-field rules of DeviceFilter
+func deviceFilterRules(sl validator.StructLevel) {
+	f := sl.Current().Interface().(model.DeviceFilter)
+	if f.MinPrice != nil && f.MaxPrice != nil && *f.MaxPrice < *f.MinPrice {
+		sl.ReportError(f.MaxPrice, "max_price", "MaxPrice", "gtefield", "min_price")
+	}
+}
+
+func kindName(k reflect.Kind) string {
+	switch k {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	default:
+		return "of a different type"
+	}
+}
+
+func message(fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "min":
+		if isString {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be greater than or equal to " + fe.Param()
+	case "lte":
+		return "must be less than or equal to " + fe.Param()
+	case "gtefield":
+		return "must be greater than or equal to " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "url":
+		return "must be a valid URL"
+	case "device_category":
+		return "must be one of: " + strings.Join(model.DeviceCategories, ", ")
+	case "event_type":
+		return "must be one of: " + strings.Join(outbox.EventTypes, ", ")
+	case "report_reason":
+		return "must be one of: " + strings.Join(model.ReportReasons, ", ")
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"device-service/internal/model"
+	"device-service/internal/problem"
+
+	gojson "github.com/goccy/go-json"
+)
+
+func TestMain(m *testing.M) {
+	if err := Init(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+type deviceInput struct {
+	Title       string  `json:"title" binding:"required,notblank,max=10"`
+	Category    string  `json:"category" binding:"required,device_category"`
+	PricePerDay float64 `json:"price_per_day" binding:"gt=0"`
+}
+
+func TestProblemValidationErrors(t *testing.T) {
+	err := Struct(deviceInput{Title: "   ", Category: "toys", PricePerDay: 0})
+	p := Problem(err)
+	if p.Type != problem.TypeValidation || p.Status != http.StatusBadRequest {
+		t.Fatalf("got %s %d, want a validation problem", p.Type, p.Status)
+	}
+	want := map[string]string{
+		"title":         "must not be blank",
+		"category":      "must be one of: " + strings.Join(model.DeviceCategories, ", "),
+		"price_per_day": "must be greater than 0",
+	}
+	assertFields(t, p, want)
+}
+
+func TestProblemStructRules(t *testing.T) {
+	min, max := 50.0, 10.0
+	f := model.DeviceFilter{MinPrice: &min, MaxPrice: &max, Sort: "recent", Page: 1, Limit: 10}
+	assertFields(t, Problem(Struct(f)), map[string]string{
+		"max_price": "must be greater than or equal to min_price",
+	})
+}
+
+func TestFieldErrors(t *testing.T) {
+	// A device revived from an old snapshot: the category was retired by migrations/011
+	d := model.Device{Name: "Drill", Category: "tools-and-hardware", PricePerDay: 5}
+	fields := FieldErrors(Struct(&d))
+	if len(fields) != 1 || fields[0].Field != "category" {
+		t.Errorf("got %v, want the category rejected", fields)
+	}
+	if FieldErrors(nil) != nil || FieldErrors(errors.New("other")) != nil {
+		t.Error("errors other than validation failures have no fields")
+	}
+}
+
+func TestProblemTypeErrors(t *testing.T) {
+	var v deviceInput
+	body := []byte(`{"price_per_day":"ten"}`)
+
+	t.Run("encoding/json", func(t *testing.T) {
+		assertFields(t, Problem(json.Unmarshal(body, &v)), map[string]string{"price_per_day": "must be a number"})
+	})
+	t.Run("goccy", func(t *testing.T) {
+		// goccy names the Go field
+		err := gojson.Unmarshal([]byte(`{"title":5}`), &v)
+		assertFields(t, Problem(err), map[string]string{"Title": "must be a string"})
+	})
+	t.Run("goccy string for number", func(t *testing.T) {
+		// goccy reports this mismatch as a syntax error
+		if p := Problem(gojson.Unmarshal(body, &v)); p.Status != http.StatusBadRequest || p.Detail != "Malformed JSON body" {
+			t.Errorf("got %d %q, want 400 Malformed JSON body", p.Status, p.Detail)
+		}
+	})
+}
+
+func TestProblemGeneric(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		detail string
+	}{
+		{name: "syntax", err: json.Unmarshal([]byte(`{"title":`), &deviceInput{}), detail: "Malformed JSON body"},
+		{name: "query number", err: &strconv.NumError{Func: "ParseInt", Num: "abc", Err: strconv.ErrSyntax}, detail: `Invalid query parameter value "abc"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Problem(tt.err)
+			if p.Status != http.StatusBadRequest || p.Detail != tt.detail || len(p.Errors) != 0 {
+				t.Errorf("got %d %q %v, want 400 %q", p.Status, p.Detail, p.Errors, tt.detail)
+			}
+		})
+	}
+}
+
+func assertFields(t *testing.T, p *problem.Details, want map[string]string) {
+	t.Helper()
+	if p.Type != problem.TypeValidation {
+		t.Fatalf("got problem type %q (%s), want %q", p.Type, p.Detail, problem.TypeValidation)
+	}
+	got := map[string]string{}
+	for _, fe := range p.Errors {
+		got[fe.Field] = fe.Message
+	}
+	if len(got) != len(want) {
+		t.Errorf("got fields %v, want %v", got, want)
+	}
+	for field, msg := range want {
+		if got[field] != msg {
+			t.Errorf("field %s: got %q, want %q", field, got[field], msg)
+		}
+	}
+}
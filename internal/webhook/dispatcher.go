@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"device-service/internal/metrics"
+	"device-service/internal/repository"
+)
+
+// Dispatcher sends pending deliveries. Failed attempts are retried with exponential
+// backoff up to MaxAttempts; a subscription failing DisableAfter times in a row is disabled.
+// Several replicas can run it at once: deliveries are leased when claimed.
+type Dispatcher struct {
+	Repo   *repository.WebhookRepository
+	Client *http.Client
+
+	BatchSize    int
+	PollInterval time.Duration
+	MaxAttempts  int
+	MaxBackoff   time.Duration
+	DisableAfter int
+}
+
+// Run sends deliveries until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.dispatchBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "webhook dispatch failed", "error", err)
+		}
+		if n == d.BatchSize && err == nil && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	// The lease outlives one attempt, so a delivery is never sent twice concurrently
+	lease := 2*d.Client.Timeout + time.Minute
+	due, err := d.Repo.ClaimDueDeliveries(ctx, d.BatchSize, lease)
+	if err != nil {
+		return 0, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, del := range due {
+		wg.Add(1)
+		go func(del repository.DueDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, del)
+		}(del)
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, del repository.DueDelivery) {
+	// The outcome must be recorded even if shutdown starts mid-request
+	store := context.WithoutCancel(ctx)
+	log := slog.With("delivery_id", del.ID, "subscription_id", del.SubscriptionID, "event_type", del.EventType)
+
+	statusCode, err := d.send(ctx, del)
+	if err == nil {
+		metrics.WebhookDeliveries.WithLabelValues("succeeded").Inc()
+		if err := d.Repo.MarkDelivered(store, del, statusCode); err != nil {
+			log.ErrorContext(ctx, "failed to record webhook delivery", "error", err)
+		}
+		return
+	}
+
+	attempt := del.Attempts + 1
+	var retryIn time.Duration
+	result := "failed"
+	if attempt < d.MaxAttempts {
+		retryIn = d.backoff(attempt)
+		result = "retry"
+	}
+	metrics.WebhookDeliveries.WithLabelValues(result).Inc()
+	log.WarnContext(ctx, "webhook delivery failed", "attempt", attempt, "retry_in", retryIn.String(), "error", err)
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+	disabled, err := d.Repo.MarkFailed(store, del, code, err.Error(), retryIn, d.DisableAfter)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to record webhook failure", "error", err)
+		return
+	}
+	if disabled {
+		log.WarnContext(ctx, "webhook subscription disabled after repeated failures", "failures", d.DisableAfter)
+	}
+}
+
+// send POSTs the signed envelope; any non-2xx answer is an error
+func (d *Dispatcher) send(ctx context.Context, del repository.DueDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "device-service-webhooks/1")
+	req.Header.Set(HeaderEventID, strconv.FormatInt(del.EventID, 10))
+	req.Header.Set(HeaderEvent, del.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(del.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(del.Secret, ts, del.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024)) // keep-alive
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is 30s, 1m, 2m, ... capped at MaxBackoff
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	b := time.Duration(math.Pow(2, float64(attempt-1))) * 30 * time.Second
+	if b <= 0 || b > d.MaxBackoff {
+		return d.MaxBackoff
+	}
+	return b
+}
@@ -0,0 +1,163 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"device-service/internal/model"
+	"device-service/internal/outbox"
+	"device-service/internal/repository"
+
+	"github.com/goccy/go-json"
+)
+
+func dueDelivery(url string) repository.DueDelivery {
+	return repository.DueDelivery{
+		WebhookDelivery: model.WebhookDelivery{
+			ID:        7,
+			EventID:   42,
+			EventType: outbox.DeviceUpdated,
+			Payload:   []byte(`{"id":42,"type":"device.updated","data":{}}`),
+		},
+		URL:    url,
+		Secret: "whsec_test",
+	}
+}
+
+func TestSendDeliversSignedRequest(t *testing.T) {
+	type received struct {
+		verifyErr         error
+		event, deliveryID string
+	}
+	got := make(chan received, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec := received{event: r.Header.Get(HeaderEvent), deliveryID: r.Header.Get(HeaderDelivery)}
+		ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			rec.verifyErr = err
+		} else {
+			rec.verifyErr = Verify("whsec_test", ts, body, r.Header.Get(HeaderSignature), time.Minute)
+		}
+		got <- rec
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	d := &Dispatcher{Client: NewClient(5*time.Second, true)}
+	code, err := d.send(context.Background(), dueDelivery(receiver.URL))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", code)
+	}
+	rec := <-got
+	if rec.verifyErr != nil {
+		t.Errorf("receiver could not verify the signature: %v", rec.verifyErr)
+	}
+	if rec.event != outbox.DeviceUpdated || rec.deliveryID != "7" {
+		t.Errorf("headers: event %q, delivery %q", rec.event, rec.deliveryID)
+	}
+}
+
+func TestSendRefusesLoopbackByDefault(t *testing.T) {
+	var called atomic.Bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}))
+	defer receiver.Close()
+
+	d := &Dispatcher{Client: NewClient(5*time.Second, false)}
+	_, err := d.send(context.Background(), dueDelivery(receiver.URL))
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("err = %v, want ErrForbiddenAddress", err)
+	}
+	if called.Load() {
+		t.Error("receiver was called")
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	})
+	receiver := httptest.NewServer(mux)
+	defer receiver.Close()
+
+	d := &Dispatcher{Client: NewClient(5*time.Second, true)}
+	code, err := d.send(context.Background(), dueDelivery(receiver.URL+"/hook"))
+	if err == nil || code != http.StatusTemporaryRedirect {
+		t.Errorf("send = %d, %v; want a failed 307", code, err)
+	}
+	if followed.Load() {
+		t.Error("redirect was followed")
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url           string
+		allowLoopback bool
+		ok            bool
+	}{
+		{"https://example.com/hook", false, true},
+		{"http://203.0.113.10:8080/hook", false, true},
+		{"ftp://example.com/hook", false, false},
+		{"/relative", false, false},
+		{"http://127.0.0.1/hook", false, false},
+		{"http://localhost:8080/hook", false, false},
+		{"http://127.0.0.1/hook", true, true},
+		{"http://localhost:8080/hook", true, true},
+		{"http://[::1]/hook", false, false},
+		{"http://10.0.0.5/hook", true, false},
+		{"http://192.168.1.1/hook", false, false},
+		{"http://172.16.0.1/hook", false, false},
+		{"http://169.254.169.254/latest/meta-data", true, false},
+		{"http://[fe80::1]/hook", false, false},
+		{"http://0.0.0.0/hook", false, false},
+		{"http://100.64.0.1/hook", false, false},
+	}
+	for _, tt := range tests {
+		err := CheckURL(tt.url, tt.allowLoopback)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckURL(%q, %v) = %v, want ok=%v", tt.url, tt.allowLoopback, err, tt.ok)
+		}
+	}
+}
+
+func TestWebhookDataHidesFavoritingUser(t *testing.T) {
+	payload, _ := json.Marshal(outbox.FavoritePayload{DeviceID: "d1", OwnerID: "o1", UserID: "u1"})
+	data, err := webhookData(outbox.Event{Type: outbox.FavoriteAdded, Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["user_id"]; ok {
+		t.Errorf("user_id leaked to webhook payload: %s", data)
+	}
+	if got["device_id"] != "d1" || got["owner_id"] != "o1" {
+		t.Errorf("payload = %s", data)
+	}
+
+	device := []byte(`{"id":"d1","owner_id":"o1"}`)
+	data, err = webhookData(outbox.Event{Type: outbox.DeviceUpdated, Payload: device})
+	if err != nil || string(data) != string(device) {
+		t.Errorf("device payload changed: %s, %v", data, err)
+	}
+}
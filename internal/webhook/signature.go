@@ -0,0 +1,61 @@
+// Package webhook delivers device events to owners' HTTP endpoints: the Sink turns
+// outbox events into pending deliveries, the Dispatcher sends them with retries.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEventID   = "X-Webhook-Event-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signaturePrefix names the algorithm in X-Webhook-Signature
+const signaturePrefix = "sha256="
+
+// Sign returns the X-Webhook-Signature value: HMAC-SHA256 over "{timestamp}.{body}"
+// keyed with the subscription secret. The timestamp lets receivers reject replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign and that timestamp is within tolerance of now.
+// Receivers written in Go can use it as is.
+func Verify(secret string, timestamp int64, body []byte, signature string, tolerance time.Duration) error {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return errors.New("webhook: unsupported signature scheme")
+	}
+	if age := time.Since(time.Unix(timestamp, 0)); math.Abs(float64(age)) > float64(tolerance) {
+		return errors.New("webhook: timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature)) {
+		return errors.New("webhook: signature mismatch")
+	}
+	return nil
+}
+
+// NewSecret generates a random signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now().Unix()
+	sig := Sign("secret", now, body)
+	if !strings.HasPrefix(sig, "sha256=") {
+		t.Fatalf("signature %q has no scheme prefix", sig)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		signature string
+		ok        bool
+	}{
+		{"valid", "secret", now, body, sig, true},
+		{"wrong secret", "other", now, body, sig, false},
+		{"tampered body", "secret", now, []byte(`{"id":2}`), sig, false},
+		{"different timestamp", "secret", now - 1, body, sig, false},
+		{"unknown scheme", "secret", now, body, "sha1=" + strings.TrimPrefix(sig, "sha256="), false},
+		{"too old", "secret", now - 600, body, Sign("secret", now-600, body), false},
+		{"from the future", "secret", now + 600, body, Sign("secret", now+600, body), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, tt.body, tt.signature, 5*time.Minute)
+			if (err == nil) != tt.ok {
+				t.Errorf("Verify = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNewSecretIsRandom(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret()
+	if a == b || !strings.HasPrefix(a, "whsec_") {
+		t.Errorf("secrets %q and %q", a, b)
+	}
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"device-service/internal/outbox"
+	"device-service/internal/repository"
+
+	"github.com/goccy/go-json"
+)
+
+// Envelope is the JSON body of every delivery
+type Envelope struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Sink is the outbox sink that fans an event out into one pending delivery per
+// matching subscription of the device owner. Delivery itself is the Dispatcher's job,
+// so a slow partner never holds up the outbox.
+type Sink struct {
+	Repo *repository.WebhookRepository
+}
+
+func (s *Sink) Name() string { return "webhooks" }
+
+func (s *Sink) Publish(ctx context.Context, ev outbox.Event) error {
+	var owner struct {
+		OwnerID string `json:"owner_id"`
+	}
+	if err := json.Unmarshal(ev.Payload, &owner); err != nil {
+		return fmt.Errorf("decode %s payload: %w", ev.Type, err)
+	}
+	if owner.OwnerID == "" {
+		return nil
+	}
+
+	data, err := webhookData(ev)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(Envelope{ID: ev.ID, Type: ev.Type, OccurredAt: ev.CreatedAt, Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = s.Repo.EnqueueDeliveries(ctx, owner.OwnerID, ev.ID, ev.Type, body)
+	return err
+}
+
+// favoriteData is what partners learn about a favorite: which device, not who
+// favorited it. The user is the favoriting user's business, not the owner's.
+type favoriteData struct {
+	DeviceID string `json:"device_id"`
+	OwnerID  string `json:"owner_id"`
+}
+
+// webhookData is the event payload as sent to the owner's endpoints
+func webhookData(ev outbox.Event) (json.RawMessage, error) {
+	if ev.Type != outbox.FavoriteAdded && ev.Type != outbox.FavoriteRemoved {
+		return ev.Payload, nil
+	}
+	var p outbox.FavoritePayload
+	if err := json.Unmarshal(ev.Payload, &p); err != nil {
+		return nil, fmt.Errorf("decode %s payload: %w", ev.Type, err)
+	}
+	return json.Marshal(favoriteData{DeviceID: p.DeviceID, OwnerID: p.OwnerID})
+}
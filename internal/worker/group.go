@@ -0,0 +1,77 @@
+// Package worker runs the service's background loops and stops them together on shutdown.
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Group owns a set of background goroutines sharing one cancellable context
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewGroup(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Go starts fn in its own goroutine. fn must return once ctx is cancelled.
+// A panic in fn is logged and stops only that worker.
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if rec := recover(); rec != nil {
+				slog.Error("background worker panicked", "worker", name, "panic", rec, "stack", string(debug.Stack()))
+			}
+		}()
+
+		slog.Info("background worker started", "worker", name)
+		fn(g.ctx)
+		slog.Info("background worker stopped", "worker", name)
+	}()
+}
+
+// Stop cancels all workers and waits for them until ctx expires
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Every runs fn right away and then every interval until ctx is cancelled.
+// Errors are logged and the next tick tries again.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "background job failed", "worker", name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -4,94 +4,121 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"log"
-	"os"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"device-service/config"
-	"device-service/internal/handler"
-	"device-service/internal/middleware"
-	"device-service/internal/repository"
+	"device-service/internal/app"
+	"device-service/internal/logging"
+	"device-service/internal/metrics"
+	"device-service/internal/tracing"
+	"device-service/internal/validation"
+	"device-service/internal/worker"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
-	"github.com/redis/go-redis/v9"
 )
 
+// traceFlushTimeout bounds the export of the last spans on exit
+const traceFlushTimeout = 5 * time.Second
+
 func main() {
-	// Загрузка .env (если есть)
-	if err := godotenv.Load(); err != nil {
-		log.Println("⚠️  No .env file found, relying on real env vars")
+	// Конфигурация: defaults → CONFIG_FILE (YAML) → .env → env
+	cfg, err := config.Load()
+	if err != nil {
+		logging.Fatal("invalid configuration", "error", err)
 	}
 
-	// 1) Проверяем и инициализируем Redis
-	redisUrl := os.Getenv("REDIS_URL")
-	if redisUrl == "" {
-		log.Fatal("REDIS_URL env var is required")
+	// JSON-логгер (slog)
+	if err := logging.Setup(cfg.Log.Level); err != nil {
+		logging.Fatal("invalid logging config", "error", err)
 	}
-	fmt.Println("REDIS_URL:", redisUrl)
+	slog.Info("configuration loaded", "sources", cfg.Sources, "config", cfg.Redacted())
+
+	// Контекст процесса: отменяется по SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	opt, err := redis.ParseURL(redisUrl)
+	// Трейсинг (OpenTelemetry)
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
 	if err != nil {
-		log.Fatalf("🙈 redis.ParseURL failed: %v", err)
+		logging.Fatal("failed to set up tracing", "error", err)
 	}
-	client := redis.NewClient(opt)
 
-	ctx := context.Background()
-	if err := client.Set(ctx, "testkey", "testvalue", 0).Err(); err != nil {
-		log.Fatalf("❌ Redis SET failed: %v", err)
+	// 1) Глобальные для процесса настройки Gin и правила валидации запросов
+	gin.SetMode(gin.ReleaseMode)
+	if err := validation.Init(); err != nil {
+		logging.Fatal("failed to register validation rules", "error", err)
 	}
-	log.Println("✅ Redis connected via URL")
 
-	// 2) Подключаемся к Postgres
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL env var is required")
-	}
-	db, err := sqlx.Connect("postgres", dbURL)
+	// 2) Postgres, Redis, Firebase Storage и репозитории
+	application, err := app.New(ctx, cfg)
 	if err != nil {
-		log.Fatalf("❌ Failed to connect to Postgres: %v", err)
+		logging.Fatal("failed to initialize application", "error", err)
 	}
-	log.Println("✅ Connected to Postgres")
-
-	// 3) Инициализируем Firebase Storage (и Redis внутри config.InitRedis, если нужно)
-	config.InitRedis() // оставляем, чтобы config.RedisClient был готов
-
-	// 4) Создаём репозитории
-	deviceRepo := repository.NewDeviceRepository(db)
-	favRepo := repository.NewFavoriteRepository(db)
-
-	// 5) Настраиваем Gin
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
 
-	// 6) Группа /api + JWT middleware
-	api := router.Group("/api")
-	api.Use(middleware.JWTAuthMiddleware())
-
-	// 7) Регистрируем маршруты в нужном порядке
-
-	// 7.1. Загрузка файлов: POST /api/upload (multipart/form-data)
-	handler.RegisterUploadHandler(api)
-
-	// 7.2. CRUD для устройств: POST/GET/PUT/DELETE /api/devices
-	handler.RegisterDeviceRoutes(api, deviceRepo)
+	// 3) Метрики пула соединений Postgres (регистрируются в глобальном реестре Prometheus)
+	if err := metrics.RegisterDBStats(application.DB.DB, "postgres"); err != nil {
+		logging.Fatal("failed to register DB metrics", "error", err)
+	}
 
-	// 7.3. Маршруты для избранного (favorites)
-	handler.RegisterFavoriteRoutes(api, favRepo)
+	// 4) Фоновые воркеры останавливаются вместе при shutdown
+	workers := worker.NewGroup(context.Background())
+	application.StartWorkers(workers)
+
+	// 5) Запуск HTTP-сервера с таймаутами
+	srv := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.HTTP.Port),
+		Handler:           application.Router(),
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+	// Shutdown не отменяет контексты запросов: SSE-потоки закрываем сами, иначе они держат drain
+	srv.RegisterOnShutdown(application.Live.Close)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, draining", "timeout", cfg.HTTP.ShutdownTimeout.String())
+	case err := <-serverErr:
+		slog.Error("server error", "error", err)
+	}
+	stop() // второй сигнал завершит процесс сразу
 
-	// 7.4. Метаданные (категории, города, регионы, тренды)
-	handler.RegisterMetaRoutes(api, deviceRepo)
+	// 6) Graceful shutdown: дожидаемся текущих запросов, останавливаем воркеры, закрываем клиентов
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancelHTTP()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		slog.Error("HTTP server did not drain in time", "error", err)
+	}
 
-	// 8) Запуск HTTP-сервера
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// У воркеров свой срок: медленный запрос не должен съесть их время
+	workersCtx, cancelWorkers := context.WithTimeout(context.Background(), cfg.HTTP.WorkerStopTimeout)
+	defer cancelWorkers()
+	if err := workers.Stop(workersCtx); err != nil {
+		// Воркер может быть посреди транзакции: соединения не закрываем, их освободит выход процесса
+		slog.Error("background workers did not stop in time, leaving connections open", "error", err)
+	} else if err := application.Close(); err != nil {
+		slog.Error("failed to close connections", "error", err)
 	}
-	log.Printf("🚀 Starting server on :%s\n", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Server error: %v", err)
+
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
 	}
+	slog.Info("server stopped")
 }
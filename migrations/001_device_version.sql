@@ -0,0 +1,4 @@
+-- Optimistic concurrency for devices: every write bumps version,
+-- which is exposed to clients as the ETag of GET /api/devices/:id.
+ALTER TABLE devices
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,23 @@
+-- Transactional outbox: domain events are written in the same transaction as the
+-- change and published by the relay (internal/outbox). Delivery is at-least-once,
+-- consumers dedupe by id.
+CREATE TABLE IF NOT EXISTS outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    event_type      TEXT        NOT NULL,
+    aggregate_id    TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error      TEXT,
+    published_at    TIMESTAMPTZ
+);
+
+-- The relay only ever scans unpublished rows
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at, id) WHERE published_at IS NULL;
+
+-- Per-device ordering check of the relay
+CREATE INDEX IF NOT EXISTS outbox_pending_aggregate_idx ON outbox (aggregate_id, id) WHERE published_at IS NULL;
+
+-- Retention cleanup of published rows
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,37 @@
+-- Outgoing webhooks: owners subscribe to events about their devices
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id                   UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id             UUID        NOT NULL,
+    url                  TEXT        NOT NULL,
+    event_types          TEXT[]      NOT NULL,
+    secret               TEXT        NOT NULL,
+    active               BOOLEAN     NOT NULL DEFAULT TRUE,
+    consecutive_failures INT         NOT NULL DEFAULT 0,
+    disabled_at          TIMESTAMPTZ,
+    disabled_reason      TEXT,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_owner_idx ON webhook_subscriptions (owner_id) WHERE active;
+
+-- One row per (subscription, event): the delivery log. Retries update the row in place.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               BIGSERIAL   PRIMARY KEY,
+    subscription_id  UUID        NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id         BIGINT      NOT NULL,
+    event_type       TEXT        NOT NULL,
+    payload          JSONB       NOT NULL,
+    status           TEXT        NOT NULL DEFAULT 'pending', -- pending | succeeded | failed
+    attempts         INT         NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INT,
+    last_error       TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at     TIMESTAMPTZ,
+    -- the outbox relay is at-least-once; this makes fan-out into deliveries idempotent
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, id DESC);
@@ -0,0 +1,5 @@
+-- Soft delete: DELETE /api/devices/:id moves the device to the owner's trash,
+-- the purge job removes it for good after the retention period.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS devices_deleted_at_idx ON devices (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,27 @@
+-- Append-only history of device changes, written in the same transaction as the change.
+-- before/after are full device snapshots; after of version N is the state a revert to N restores.
+CREATE TABLE IF NOT EXISTS device_audit (
+    id         BIGSERIAL   PRIMARY KEY,
+    device_id  UUID        NOT NULL, -- no FK: history outlives purged devices
+    version    BIGINT      NOT NULL, -- device version after the change
+    actor_id   TEXT        NOT NULL,
+    action     TEXT        NOT NULL,
+    changes    JSONB       NOT NULL, -- {"field": {"from": ..., "to": ...}}
+    before     JSONB,
+    after      JSONB,
+    request_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS device_audit_device_idx ON device_audit (device_id, id DESC);
+
+CREATE OR REPLACE FUNCTION device_audit_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'device_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS device_audit_immutable ON device_audit;
+CREATE TRIGGER device_audit_immutable
+    BEFORE UPDATE OR DELETE ON device_audit
+    FOR EACH ROW EXECUTE FUNCTION device_audit_immutable();
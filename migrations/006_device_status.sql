@@ -0,0 +1,13 @@
+-- Listing lifecycle: draft → pending_review → published ⇄ paused, archived.
+-- Devices that existed before the lifecycle are already live, so they start out published;
+-- new devices start as drafts.
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'published';
+ALTER TABLE devices ALTER COLUMN status SET DEFAULT 'draft';
+
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS devices_status_check;
+ALTER TABLE devices ADD CONSTRAINT devices_status_check
+    CHECK (status IN ('draft', 'pending_review', 'published', 'paused', 'archived'));
+
+-- The public catalog only ever reads published devices
+CREATE INDEX IF NOT EXISTS devices_published_created_at_idx
+    ON devices (created_at DESC) WHERE status = 'published' AND deleted_at IS NULL;
@@ -0,0 +1,26 @@
+-- Moderation queue: a device enters it when submitted for review or materially edited
+-- while listed. Automatic checks store their findings in flags; moderators decide.
+CREATE TABLE IF NOT EXISTS moderation_items (
+    id             BIGSERIAL   PRIMARY KEY,
+    device_id      UUID        NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    kind           TEXT        NOT NULL,                   -- new | edit
+    device_version BIGINT      NOT NULL,                   -- version the checks ran against
+    status         TEXT        NOT NULL DEFAULT 'pending', -- pending | approved | rejected | changes_requested | withdrawn
+    flags          JSONB       NOT NULL DEFAULT '[]',
+    flagged        BOOLEAN     NOT NULL DEFAULT FALSE,
+    reason         TEXT,
+    moderator_id   TEXT,
+    decided_at     TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- At most one open item per device: a new edit updates it instead
+CREATE UNIQUE INDEX IF NOT EXISTS moderation_items_pending_device_idx
+    ON moderation_items (device_id) WHERE status = 'pending';
+
+-- The queue: flagged first, then oldest first
+CREATE INDEX IF NOT EXISTS moderation_items_queue_idx
+    ON moderation_items (flagged DESC, created_at) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS moderation_items_device_idx ON moderation_items (device_id, id DESC);
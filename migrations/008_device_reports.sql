@@ -0,0 +1,19 @@
+-- User reports of listings (scams, prohibited items, ...). A user has at most one
+-- open report per device; enough open reports hide the device pending review.
+CREATE TABLE IF NOT EXISTS device_reports (
+    id              BIGSERIAL   PRIMARY KEY,
+    device_id       UUID        NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    reporter_id     TEXT        NOT NULL,
+    reason          TEXT        NOT NULL,
+    comment         TEXT        NOT NULL DEFAULT '',
+    status          TEXT        NOT NULL DEFAULT 'open', -- open | upheld | dismissed
+    resolution_note TEXT,
+    resolved_by     TEXT,
+    resolved_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS device_reports_open_reporter_idx
+    ON device_reports (device_id, reporter_id) WHERE status = 'open';
+
+CREATE INDEX IF NOT EXISTS device_reports_open_idx ON device_reports (created_at) WHERE status = 'open';
@@ -0,0 +1,14 @@
+-- Per-device daily counters (UTC days). Views and list impressions are buffered in
+-- Redis and added here by the analytics flush job; favorites are counted in the
+-- transaction that adds or removes the favorite.
+CREATE TABLE IF NOT EXISTS device_daily_stats (
+    device_id         UUID   NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    day               DATE   NOT NULL,
+    views             BIGINT NOT NULL DEFAULT 0,
+    impressions       BIGINT NOT NULL DEFAULT 0,
+    favorites_added   BIGINT NOT NULL DEFAULT 0,
+    favorites_removed BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (device_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS device_daily_stats_day_idx ON device_daily_stats (day);
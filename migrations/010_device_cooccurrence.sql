@@ -0,0 +1,12 @@
+-- Item-to-item model for personal recommendations: for every device, the devices most
+-- often favorited by the same users. Rebuilt periodically from favorites by the
+-- recommendations job; score is the cosine similarity of the two devices' fans.
+CREATE TABLE IF NOT EXISTS device_cooccurrence (
+    device_id  UUID             NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    similar_id UUID             NOT NULL REFERENCES devices (id) ON DELETE CASCADE,
+    score      DOUBLE PRECISION NOT NULL,
+    users      INTEGER          NOT NULL, -- users who favorited both
+    PRIMARY KEY (device_id, similar_id)
+);
+
+CREATE INDEX IF NOT EXISTS device_cooccurrence_similar_idx ON device_cooccurrence (similar_id);
@@ -0,0 +1,44 @@
+-- Categories became a closed list (model.DeviceCategories) after free-form values were
+-- already stored. Map the existing values onto the list so those devices stay editable
+-- and GET /api/categories only returns values the catalog filter accepts; anything
+-- unrecognised becomes "other". The version bump invalidates clients' ETags.
+UPDATE devices
+SET category = CASE
+        WHEN LOWER(TRIM(category)) IN ('phones', 'laptops', 'tablets', 'cameras', 'audio', 'gaming',
+                                       'drones', 'tv', 'appliances', 'tools', 'other')
+            THEN LOWER(TRIM(category))
+        WHEN LOWER(TRIM(category)) IN ('phone', 'smartphone', 'smartphones', 'mobile', 'mobiles',
+                                       'телефон', 'телефоны', 'смартфон', 'смартфоны')
+            THEN 'phones'
+        WHEN LOWER(TRIM(category)) IN ('laptop', 'notebook', 'notebooks', 'computer', 'computers',
+                                       'ноутбук', 'ноутбуки', 'компьютеры')
+            THEN 'laptops'
+        WHEN LOWER(TRIM(category)) IN ('tablet', 'ipad', 'планшет', 'планшеты')
+            THEN 'tablets'
+        WHEN LOWER(TRIM(category)) IN ('camera', 'photo', 'video', 'камера', 'камеры', 'фотоаппараты', 'фото')
+            THEN 'cameras'
+        WHEN LOWER(TRIM(category)) IN ('headphones', 'speakers', 'sound', 'music', 'наушники', 'колонки', 'аудио')
+            THEN 'audio'
+        WHEN LOWER(TRIM(category)) IN ('games', 'console', 'consoles', 'игры', 'приставки')
+            THEN 'gaming'
+        WHEN LOWER(TRIM(category)) IN ('drone', 'дрон', 'дроны', 'квадрокоптеры')
+            THEN 'drones'
+        WHEN LOWER(TRIM(category)) IN ('tvs', 'television', 'телевизор', 'телевизоры')
+            THEN 'tv'
+        WHEN LOWER(TRIM(category)) IN ('appliance', 'home appliances', 'бытовая техника', 'техника')
+            THEN 'appliances'
+        WHEN LOWER(TRIM(category)) IN ('tool', 'инструмент', 'инструменты')
+            THEN 'tools'
+        ELSE 'other'
+    END,
+    version = version + 1,
+    updated_at = NOW()
+WHERE category IS NULL
+   OR category NOT IN ('phones', 'laptops', 'tablets', 'cameras', 'audio', 'gaming',
+                       'drones', 'tv', 'appliances', 'tools', 'other');
+
+-- Keep it that way; extend together with model.DeviceCategories
+ALTER TABLE devices DROP CONSTRAINT IF EXISTS devices_category_check;
+ALTER TABLE devices ADD CONSTRAINT devices_category_check
+    CHECK (category IN ('phones', 'laptops', 'tablets', 'cameras', 'audio', 'gaming',
+                        'drones', 'tv', 'appliances', 'tools', 'other'));